@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// adminRequest sends request to the admin API of running Manopus and returns the body of the response.
+// Returns error with status and body of the response if status is not OK.
+func adminRequest(method, url, token, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(url, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	client := http.Client{Timeout: time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(indentJSON(buf)))
+	}
+	return buf, nil
+}
+
+// indentJSON returns indented JSON or the data as is if it is not valid JSON
+func indentJSON(buf []byte) string {
+	var out bytes.Buffer
+	if json.Indent(&out, buf, "", "  ") != nil {
+		return string(buf)
+	}
+	return out.String()
+}
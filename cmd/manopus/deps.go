@@ -13,6 +13,7 @@ import (
 
 	//Stores
 	_ "github.com/geliar/manopus/pkg/store/boltdb"
+	_ "github.com/geliar/manopus/pkg/store/memory"
 
 	//Reporters
 	_ "github.com/geliar/manopus/pkg/report/fs"
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/geliar/manopus/pkg/sequencer"
+
+	flag "github.com/ogier/pflag"
+)
+
+// history prints history of the sequence instance from the admin API of running Manopus
+func history(args []string) int {
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	adminURL := flags.StringP("url", "u", "http://localhost:8080/admin", "URL of the admin API")
+	token := flags.StringP("token", "t", os.Getenv("MANOPUS_ADMIN_TOKEN"), "Token of the admin API (MANOPUS_ADMIN_TOKEN by default)")
+	raw := flags.BoolP("json", "j", false, "Print history as JSON")
+	flags.Usage = func() {
+		println("Usage: " + os.Args[0] + " history [options] <sequence-id>")
+		println("Prints how the instance of the sequence moved through its steps\n")
+		println("Options and flags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	body, err := adminRequest(http.MethodGet, *adminURL, *token, "/sequencer/instances/"+url.PathEscape(flags.Arg(0))+"/history", nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *raw {
+		fmt.Println(indentJSON(body))
+		return 0
+	}
+	var h sequencer.History
+	if err := json.Unmarshal(body, &h); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Sequence: %s\nInstance: %s\n", h.Name, h.ID)
+	if h.Parent != "" {
+		fmt.Printf("Parent:   %s\n", h.Parent)
+	}
+	fmt.Printf("Started:  %s\n", h.Started.Format(time.RFC3339))
+	if h.Finished != nil {
+		fmt.Printf("Finished: %s\n", h.Finished.Format(time.RFC3339))
+	}
+	fmt.Println()
+	for _, e := range h.Entries {
+		step := fmt.Sprint(e.Step)
+		if e.StepName != "" {
+			step += " (" + e.StepName + ")"
+		}
+		line := []string{e.Time.Format(time.RFC3339), e.Kind, "step " + step}
+		if e.EventInput != "" {
+			line = append(line, fmt.Sprintf("event %s/%s/%s", e.EventInput, e.EventType, e.EventID))
+		}
+		if e.Status != "" {
+			line = append(line, "status "+e.Status)
+		}
+		if len(e.Outputs) > 0 {
+			line = append(line, "outputs "+strings.Join(e.Outputs, ","))
+		}
+		if e.Detail != "" {
+			line = append(line, e.Detail)
+		}
+		fmt.Println(strings.Join(line, "  "))
+	}
+	return 0
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/geliar/manopus/pkg/store"
@@ -14,7 +15,9 @@ import (
 	"github.com/geliar/manopus/pkg/input"
 	"github.com/geliar/manopus/pkg/log"
 	"github.com/geliar/manopus/pkg/output"
+	"github.com/geliar/manopus/pkg/recorder"
 	"github.com/geliar/manopus/pkg/sequencer"
+	"github.com/geliar/manopus/pkg/trace"
 
 	flag "github.com/ogier/pflag"
 )
@@ -22,7 +25,20 @@ import (
 var help = flag.BoolP("help", "h", false, "Show this page")
 var noload = flag.BoolP("noload", "n", false, "Don't load unfinished sequences from store")
 
+// commands subcommands of Manopus
+var commands = map[string]func(args []string) int{
+	"trigger": trigger,
+	"replay":  replayRecording,
+	"test":    runTests,
+	"history": history,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	ctx = log.Logger.WithContext(ctx)
@@ -41,22 +57,36 @@ func main() {
 	if cfg == nil || sequencerInstance == nil {
 		log.Fatal().Msg("No configuration provided")
 	}
-	wait(ctx, cancel, cfg, sequencerInstance, httpServer)
+	wait(ctx, cancel, configFiles, cfg, sequencerInstance, httpServer)
 }
 
 func showUsage() {
 	println("Usage: " + os.Args[0] + " [options] [config files or dirs]...")
-	println("Starts Manopus omnichannel automation bot\n")
+	println("       " + os.Args[0] + " <command> [options]")
+	println("Starts Manopus omnichannel automation bot")
+	println("Send SIGHUP to reload configuration without restart\n")
 	println("Options and flags:")
 	println("  -n, --noload: Don't load unfinished sequences from store")
-	println("  -h, --help: Show this page")
+	println("  -h, --help: Show this page\n")
+	println("Commands:")
+	println("  trigger: Send synthetic event to the running Manopus")
+	println("  replay: Feed recorded input events to the sequences without sending responses")
+	println("  test: Run tests of the sequences")
+	println("  history: Show history of the sequence instance of the running Manopus")
 }
 
-func wait(ctx context.Context, cancel context.CancelFunc, cfg *config.Config, sequencerInstance *sequencer.Sequencer, httpServer *http.Server) {
+func wait(ctx context.Context, cancel context.CancelFunc, configFiles []string, cfg *config.Config, sequencerInstance *sequencer.Sequencer, httpServer *http.Server) {
 	stopSignal := make(chan os.Signal, 1)
 	signal.Notify(stopSignal, os.Interrupt)
+	reloadSignal := make(chan os.Signal, 1)
+	signal.Notify(reloadSignal, syscall.SIGHUP)
 	for {
 		select {
+		case <-reloadSignal:
+			log.Info().Msg("Hangup signal received")
+			if err := config.Reload(ctx, configFiles, cfg); err != nil {
+				log.Error().Err(err).Msg("Configuration reload has been rejected")
+			}
 		case <-stopSignal:
 			log.Info().Msg("Interrupt signal received")
 			if cfg.ShutdownTimeout != 0 {
@@ -89,6 +119,8 @@ func wait(ctx context.Context, cancel context.CancelFunc, cfg *config.Config, se
 				wg.Done()
 			}()
 			wg.Wait()
+			recorder.Stop(ctx)
+			trace.Stop(ctx)
 			log.Info().Msg("Manopus has been gracefully stopped")
 			return
 		}
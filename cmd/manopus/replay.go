@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/geliar/manopus/pkg/config"
+	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/replay"
+
+	flag "github.com/ogier/pflag"
+)
+
+// replayRecording feeds recorded events to the sequencer built from the config files and prints the results
+func replayRecording(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.Usage = func() {
+		println("Usage: " + os.Args[0] + " replay <recording file> [config files or dirs]...")
+		println("Feeds recorded input events to the sequences from the configuration")
+		println("Responses are captured instead of sending to outputs and printed as JSON Lines")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return 2
+	}
+	ctx := log.Logger.WithContext(context.Background())
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+	cfg, err := config.Load(ctx, flags.Args()[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	enc := json.NewEncoder(os.Stdout)
+	err = replay.Run(ctx, cfg, f, func(result replay.Result) {
+		_ = enc.Encode(result)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/testrunner"
+
+	flag "github.com/ogier/pflag"
+)
+
+// runTests runs tests of the sequences from the test files and prints the results
+func runTests(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	junit := flags.StringP("junit", "j", "", "Write JUnit XML report to the file")
+	verbose := flags.BoolP("verbose", "v", false, "Show logs of the sequences")
+	flags.Usage = func() {
+		println("Usage: " + os.Args[0] + " test [options] [test files or dirs]...")
+		println("Runs tests of the sequences with in-memory connectors and stores\n")
+		println("Options and flags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	l := log.Output(ioutil.Discard)
+	if *verbose {
+		l = log.Logger
+	}
+	ctx := l.WithContext(context.Background())
+
+	var files []string
+	for _, name := range flags.Args() {
+		err := filepath.Walk(name, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() && (filepath.Ext(path) == ".yaml" || filepath.Ext(path) == ".yml") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	failed := false
+	var results []testrunner.Result
+	for _, f := range files {
+		suite, err := testrunner.LoadSuite(f)
+		if err != nil {
+			fmt.Printf("FAIL\t%s: %s\n", f, err)
+			failed = true
+			continue
+		}
+		suiteResults, err := testrunner.Run(ctx, suite)
+		if err != nil {
+			fmt.Printf("FAIL\t%s: %s\n", f, err)
+			failed = true
+			continue
+		}
+		for _, r := range suiteResults {
+			if r.Passed() {
+				fmt.Printf("--- PASS: %s (%.3fs)\n", r.Name, r.Duration.Seconds())
+				continue
+			}
+			failed = true
+			fmt.Printf("--- FAIL: %s (%.3fs)\n", r.Name, r.Duration.Seconds())
+			for _, failure := range r.Failures {
+				fmt.Printf("    %s\n", failure)
+			}
+		}
+		results = append(results, suiteResults...)
+	}
+	if *junit != "" {
+		f, err := os.Create(*junit)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		err = testrunner.WriteJUnit(f, results)
+		_ = f.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if failed {
+		fmt.Println("FAIL")
+		return 1
+	}
+	fmt.Println("PASS")
+	return 0
+}
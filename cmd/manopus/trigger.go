@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	flag "github.com/ogier/pflag"
+)
+
+// trigger sends synthetic event to the admin API of running Manopus and prints the result
+func trigger(args []string) int {
+	flags := flag.NewFlagSet("trigger", flag.ContinueOnError)
+	url := flags.StringP("url", "u", "http://localhost:8080/admin", "URL of the admin API")
+	token := flags.StringP("token", "t", os.Getenv("MANOPUS_ADMIN_TOKEN"), "Token of the admin API (MANOPUS_ADMIN_TOKEN by default)")
+	input := flags.StringP("input", "i", "", "Name of the input the event came from")
+	eventType := flags.StringP("type", "e", "", "Type of the event")
+	data := flags.StringP("data", "d", "{}", "JSON data of the event")
+	sequence := flags.StringP("sequence", "s", "", "Name of the sequence to trigger (all sequences by default)")
+	flags.Usage = func() {
+		println("Usage: " + os.Args[0] + " trigger [options]")
+		println("Sends synthetic event to the running Manopus and prints callback and responses\n")
+		println("Options and flags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *input == "" {
+		flags.Usage()
+		return 2
+	}
+	var event struct {
+		Input    string          `json:"input"`
+		Type     string          `json:"type"`
+		Data     json.RawMessage `json:"data"`
+		Sequence string          `json:"sequence,omitempty"`
+	}
+	event.Input = *input
+	event.Type = *eventType
+	event.Sequence = *sequence
+	event.Data = json.RawMessage(*data)
+	if !json.Valid(event.Data) {
+		fmt.Fprintln(os.Stderr, "Data of the event should be valid JSON")
+		return 2
+	}
+	buf, _ := json.Marshal(event)
+	body, err := adminRequest(http.MethodPost, *url, *token, "/sequencer/trigger", bytes.NewReader(buf))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(indentJSON(body))
+	return 0
+}
@@ -1,17 +1,24 @@
 module github.com/geliar/manopus
 
+go 1.22
+
 require (
+	cloud.google.com/go v0.34.0 // indirect
 	github.com/DLag/midsimple v0.1.1
 	github.com/DLag/starlark-modules v0.0.0-20190404104515-a41e32464300
 	github.com/DLag/starlight v0.0.0-20190131132040-cc75178c5236
 	github.com/davecgh/go-spew v1.1.1
 	github.com/geliar/yaml v0.0.0-20181219141838-8ed8a3331646
 	github.com/golang/protobuf v1.3.1 // indirect
+	github.com/google/go-github v17.0.0+incompatible // indirect
 	github.com/google/go-github/v24 v24.0.1
+	github.com/google/go-querystring v1.0.0 // indirect
 	github.com/gorilla/websocket v1.4.0 // indirect
 	github.com/k0kubun/colorstring v0.0.0-20150214042306-9440f1994b88 // indirect
 	github.com/k0kubun/pp v3.0.1+incompatible // indirect
 	github.com/kr/pretty v0.1.0 // indirect
+	github.com/kr/pty v1.1.1 // indirect
+	github.com/kr/text v0.1.0 // indirect
 	github.com/ktrysmt/go-bitbucket v0.4.1
 	github.com/lusis/go-slackbot v0.0.0-20180109053408-401027ccfef5 // indirect
 	github.com/lusis/slack-test v0.0.0-20190408224659-6cf59653add2 // indirect
@@ -21,8 +28,11 @@ require (
 	github.com/nlopes/slack v0.5.0
 	github.com/ogier/pflag v0.0.1
 	github.com/pkg/errors v0.8.1
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/rs/xid v1.2.1 // indirect
 	github.com/rs/zerolog v1.13.0
+	github.com/starlight-go/starlight v0.0.0-20181207205707-b06f321544f3 // indirect
+	github.com/stretchr/objx v0.1.1 // indirect
 	github.com/stretchr/testify v1.3.0
 	github.com/tidwall/gjson v1.2.1
 	github.com/tidwall/match v1.0.1 // indirect
@@ -31,9 +41,12 @@ require (
 	github.com/zenazn/goji v0.9.0 // indirect
 	go.etcd.io/bbolt v1.3.2
 	go.starlark.net v0.0.0-20190411183516-fab11d534b66
+	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2 // indirect
 	golang.org/x/net v0.0.0-20190419010253-1f3472d942ba // indirect
 	golang.org/x/oauth2 v0.0.0-20190402181905-9f3314589c9a
+	golang.org/x/sync v0.0.0-20181221193216-37e7f081c4d4 // indirect
 	golang.org/x/sys v0.0.0-20190418153312-f0ce4c0180be // indirect
+	golang.org/x/text v0.3.0 // indirect
 	google.golang.org/appengine v1.5.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 	gopkg.in/go-playground/webhooks.v5 v5.8.0
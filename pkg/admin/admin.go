@@ -0,0 +1,101 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	mhttp "github.com/geliar/manopus/pkg/http"
+)
+
+const defaultPath = "/admin"
+
+// Server implementation of Manopus admin API
+type Server struct {
+	config  Config
+	mux     *http.ServeMux
+	mounted bool
+	sync.RWMutex
+}
+
+var server Server
+
+// Init configures admin API and mounts it on the Manopus HTTP server.
+// It can be called again to apply the new configuration.
+func Init(ctx context.Context, config Config) {
+	server.Init(ctx, config)
+}
+
+// Handle registers handler of the admin API on the path relative to the admin API prefix
+func Handle(ctx context.Context, path string, h http.Handler) {
+	server.Handle(ctx, path, h)
+}
+
+// Init configures admin API and mounts it on the Manopus HTTP server
+func (s *Server) Init(ctx context.Context, config Config) {
+	l := logger(ctx)
+	if config.Path == "" {
+		config.Path = defaultPath
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.mounted && config.Path != s.config.Path {
+		l.Warn().Str("http_path", s.config.Path).Msg("Changing path of the admin API requires restart")
+		config.Path = s.config.Path
+	}
+	s.config = config
+	if config.Token == "" {
+		l.Info().Msg("Admin API is disabled, token is not configured")
+		return
+	}
+	if !s.mounted {
+		mhttp.AddHandler(ctx, config.Path, s)
+		s.mounted = true
+		l.Info().Str("http_path", config.Path).Msg("Admin API is enabled")
+	}
+}
+
+// Handle registers handler of the admin API on the path relative to the admin API prefix
+func (s *Server) Handle(ctx context.Context, path string, h http.Handler) {
+	l := logger(ctx).With().Str("http_path", path).Logger()
+	s.Lock()
+	defer s.Unlock()
+	if s.mux == nil {
+		s.mux = http.NewServeMux()
+	}
+	s.mux.Handle(path, h)
+	l.Debug().Msg("Added admin API handler")
+}
+
+// ServeHTTP checks token of the request and routes it to the admin API handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.RLock()
+	config := s.config
+	mux := s.mux
+	s.RUnlock()
+	if config.Token == "" || mux == nil {
+		http.NotFound(w, r)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(config.Token)) != 1 {
+		WriteError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	http.StripPrefix(config.Path, mux).ServeHTTP(w, r)
+}
+
+// WriteJSON writes value as JSON response with specified status code
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// WriteError writes error message as JSON response with specified status code
+func WriteError(w http.ResponseWriter, status int, message string) {
+	WriteJSON(w, status, map[string]interface{}{"error": message})
+}
@@ -0,0 +1,10 @@
+package admin
+
+// Config config structure of Manopus admin API
+type Config struct {
+	//Path (optional) path prefix of the admin API (/admin by default)
+	Path string `yaml:"path"`
+	//Token secret token which should be sent in Authorization header as "Bearer <token>".
+	//Admin API is disabled if token is empty
+	Token string `yaml:"token"`
+}
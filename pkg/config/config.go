@@ -2,18 +2,25 @@ package config
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
+	nethttp "net/http"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sync"
 
+	"github.com/geliar/manopus/pkg/admin"
 	"github.com/geliar/manopus/pkg/connector"
 	"github.com/geliar/manopus/pkg/http"
 	"github.com/geliar/manopus/pkg/input"
-	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/metrics"
+	"github.com/geliar/manopus/pkg/output"
+	"github.com/geliar/manopus/pkg/recorder"
 	"github.com/geliar/manopus/pkg/report"
 	"github.com/geliar/manopus/pkg/sequencer"
 	"github.com/geliar/manopus/pkg/store"
+	"github.com/geliar/manopus/pkg/trace"
 
 	"github.com/geliar/yaml"
 )
@@ -22,6 +29,9 @@ func init() {
 	yaml.DefaultMapType = reflect.TypeOf(map[string]interface{}{})
 }
 
+// reloadMu serializes reloads of the configuration
+var reloadMu sync.Mutex
+
 // Config contains structure of the Manopus manifest
 type Config struct {
 	//ShutdownTimeout timeout of graceful shutdown
@@ -36,19 +46,72 @@ type Config struct {
 	Report report.Config
 	//HTTP server config
 	HTTP http.Config
+	//Admin API config
+	Admin admin.Config `yaml:"admin"`
+	//Recorder config of the recorder of input events
+	Recorder recorder.Config `yaml:"recorder"`
+	//Trace config of the tracing of event processing
+	Trace trace.Config `yaml:"trace"`
 }
 
 // InitConfig initializes Manopus with configuration data
 func InitConfig(ctx context.Context, configs []string, noload bool) (*Config, *sequencer.Sequencer, *http.Server) {
 	l := logger(ctx)
 
-	var files []string
 	if len(configs) == 0 {
 		return nil, nil, nil
 	}
+	c, err := Load(ctx, configs)
+	if err != nil {
+		l.Fatal().Err(err).Msg("Cannot parse config files")
+	}
+	if err := c.Validate(); err != nil {
+		l.Fatal().Err(err).Msg("Cannot validate configuration")
+	}
+
+	//Tracing
+	trace.Init(ctx, c.Trace)
+
+	//HTTP server
+	h := http.Init(ctx, c.HTTP)
+	if h != nil {
+		http.AddHandler(ctx, "/metrics", metrics.Handler())
+		admin.Init(ctx, c.Admin)
+		admin.Handle(ctx, "/reload", reloadHandler(ctx, configs, c))
+		admin.Handle(ctx, "/sequencer/", nethttp.StripPrefix("/sequencer", c.Sequencer.AdminHandler(ctx)))
+	}
+
+	//Stores
+	for i := range c.Stores {
+		store.ConfigureStore(ctx, i, c.Stores[i])
+	}
+
+	//Connectors
+	for i := range c.Connectors {
+		connector.Configure(ctx, i, c.Connectors[i])
+	}
+
+	//Report
+	report.Init(ctx, c.Report)
+
+	//Sequencer
+	c.Sequencer.Init(ctx, noload)
+	recorder.Init(ctx, c.Recorder)
+	input.RegisterHandlerAll(ctx, recorder.Wrap(c.Sequencer.Roll))
+	l.Info().Msg("Configuration stage is complete")
+	return c, &c.Sequencer, h
+}
+
+// Load reads and parses configuration files or directories with configuration files
+func Load(ctx context.Context, configs []string) (*Config, error) {
+	l := logger(ctx)
+	var files []string
 	for _, name := range configs {
 		err := filepath.Walk(name,
 			func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
 				if info.Mode().IsRegular() && (filepath.Ext(path) == ".yaml" || filepath.Ext(path) == ".yml") {
 					files = append(files, path)
 				}
@@ -61,7 +124,7 @@ func InitConfig(ctx context.Context, configs []string, noload bool) (*Config, *s
 	}
 
 	var configBuffer []byte
-	log.Info().Strs("files", files).Msg("Reading config files")
+	l.Info().Strs("files", files).Msg("Reading config files")
 	for _, f := range files {
 		buf, _ := ioutil.ReadFile(f)
 		configBuffer = append(configBuffer, buf...)
@@ -69,30 +132,130 @@ func InitConfig(ctx context.Context, configs []string, noload bool) (*Config, *s
 	}
 
 	var c Config
-
 	if err := yaml.Unmarshal(configBuffer, &c); err != nil {
-		l.Fatal().Err(err).Msg("Cannot parse config files")
+		return nil, err
 	}
+	return &c, nil
+}
 
-	//HTTP server
-	h := http.Init(ctx, c.HTTP)
+// Validate checks configuration before applying it
+func (c *Config) Validate() error {
+	for name, s := range c.Stores {
+		if !store.BuilderExists(s.Type) {
+			return fmt.Errorf("store '%s': cannot find store type '%s'", name, s.Type)
+		}
+	}
+	for name, cn := range c.Connectors {
+		if !connector.Exists(cn.Type) {
+			return fmt.Errorf("connector '%s': cannot find connector type '%s'", name, cn.Type)
+		}
+		if err := connector.Validate(cn); err != nil {
+			return fmt.Errorf("connector '%s': %w", name, err)
+		}
+	}
+	if !report.Exists(c.Report.Driver) {
+		return fmt.Errorf("cannot find report driver '%s'", c.Report.Driver)
+	}
+	if c.Sequencer.Store != "" {
+		if _, ok := c.Stores[c.Sequencer.Store]; !ok {
+			return fmt.Errorf("cannot find store '%s' of the sequencer", c.Sequencer.Store)
+		}
+	}
+	return c.Sequencer.Validate()
+}
 
-	//Connectors
-	for i := range c.Connectors {
-		connector.Configure(ctx, i, c.Connectors[i])
+// Reload reads configuration files again and applies changes to the running Manopus.
+// Connectors which configuration has been changed are restarted, other ones keep running.
+// New configuration is rejected if it fails validation or the sequencer rejects it,
+// in this case running connectors are not changed.
+func Reload(ctx context.Context, configs []string, current *Config) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	l := logger(ctx)
+	l.Info().Msg("Reloading configuration")
+	next, err := Load(ctx, configs)
+	if err != nil {
+		return err
+	}
+	if err := next.Validate(); err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(next.HTTP, current.HTTP) {
+		l.Warn().Msg("Changing HTTP server configuration requires restart")
 	}
 
 	//Stores
-	for i := range c.Stores {
-		store.ConfigureStore(ctx, i, c.Stores[i])
+	//Running stores keep their configuration until restart, only new stores are configured
+	stores := make(map[string]store.Config, len(current.Stores)+len(next.Stores))
+	for name, s := range current.Stores {
+		stores[name] = s
+	}
+	for name, s := range next.Stores {
+		old, ok := current.Stores[name]
+		if !ok {
+			store.ConfigureStore(ctx, name, s)
+			stores[name] = s
+			continue
+		}
+		if !reflect.DeepEqual(old, s) {
+			l.Warn().Str("store_name", name).Msg("Changing configuration of the store requires restart")
+		}
+	}
+	current.Stores = stores
+
+	//Sequencer is reloaded before connectors, so rejected configuration does not restart them
+	if err := current.Sequencer.Reload(ctx, &next.Sequencer); err != nil {
+		return err
 	}
+	admin.Init(ctx, next.Admin)
+	current.Admin = next.Admin
+
+	//Connectors
+	for name, cn := range current.Connectors {
+		if n, ok := next.Connectors[name]; ok && reflect.DeepEqual(cn, n) {
+			continue
+		}
+		l.Info().Str("connector_name", name).Msg("Stopping removed or changed connector")
+		input.Unregister(ctx, name)
+		output.Unregister(ctx, name)
+	}
+	for name, cn := range next.Connectors {
+		if old, ok := current.Connectors[name]; ok && reflect.DeepEqual(cn, old) {
+			continue
+		}
+		l.Info().Str("connector_name", name).Msg("Starting new or changed connector")
+		connector.Configure(ctx, name, cn)
+		input.RegisterHandler(ctx, name, recorder.Wrap(current.Sequencer.Roll))
+	}
+	current.Connectors = next.Connectors
 
 	//Report
-	report.Init(ctx, c.Report)
+	if !reflect.DeepEqual(next.Report, current.Report) {
+		report.Init(ctx, next.Report)
+		current.Report = next.Report
+	}
+	recorder.Init(ctx, next.Recorder)
+	current.Recorder = next.Recorder
+	trace.Init(ctx, next.Trace)
+	current.Trace = next.Trace
+	current.ShutdownTimeout = next.ShutdownTimeout
+	l.Info().Msg("Configuration has been reloaded")
+	return nil
+}
 
-	//Sequencer
-	c.Sequencer.Init(ctx, noload)
-	input.RegisterHandlerAll(ctx, c.Sequencer.Roll)
-	l.Info().Msg("Configuration stage is complete")
-	return &c, &c.Sequencer, h
+func reloadHandler(ctx context.Context, configs []string, current *Config) nethttp.Handler {
+	return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		l := logger(ctx)
+		if r.Method != nethttp.MethodPost {
+			admin.WriteError(w, nethttp.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if err := Reload(ctx, configs, current); err != nil {
+			l.Error().Err(err).Msg("Configuration reload has been rejected")
+			admin.WriteError(w, nethttp.StatusBadRequest, err.Error())
+			return
+		}
+		admin.WriteJSON(w, nethttp.StatusOK, map[string]interface{}{"result": "reloaded"})
+	})
 }
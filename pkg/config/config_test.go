@@ -0,0 +1,256 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/geliar/manopus/pkg/input"
+	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/output"
+	"github.com/geliar/manopus/pkg/payload"
+
+	//Connectors
+	_ "github.com/geliar/manopus/pkg/connector/bitbucket"
+	_ "github.com/geliar/manopus/pkg/connector/github"
+	_ "github.com/geliar/manopus/pkg/connector/http"
+	_ "github.com/geliar/manopus/pkg/connector/slack"
+	_ "github.com/geliar/manopus/pkg/connector/timer"
+
+	//Processors
+	_ "github.com/geliar/manopus/pkg/processor/starlark"
+
+	//Stores
+	_ "github.com/geliar/manopus/pkg/store/boltdb"
+	_ "github.com/geliar/manopus/pkg/store/memory"
+
+	//Reporters
+	_ "github.com/geliar/manopus/pkg/report/fs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate_Examples(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	for _, example := range []string{"bitbucket", "github", "test"} {
+		t.Run(example, func(t *testing.T) {
+			a := assert.New(t)
+			c, err := Load(ctx, []string{filepath.Join("../../examples", example)})
+			a.NoError(err)
+			a.NoError(c.Validate())
+		})
+	}
+}
+
+func TestValidate_Connectors(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	dir, err := ioutil.TempDir("", "manopus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	for name, schedule := range map[string]string{
+		"cron":     "cron: '61 * * * *'",
+		"timezone": "cron: '0 * * * *'\n        timezone: Mars/Olympus",
+		"missed":   "cron: '0 * * * *'\n        missed: sometimes",
+	} {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+			writeConfig(t, dir, `
+connectors:
+  clock:
+    type: timer
+    config:
+      schedules:
+      - name: hourly
+        `+schedule+`
+`, "")
+			c, err := Load(ctx, []string{dir})
+			a.NoError(err)
+			err = c.Validate()
+			if a.Error(err) {
+				a.Contains(err.Error(), "schedule 'hourly'")
+			}
+		})
+	}
+}
+
+func writeConfig(t *testing.T, dir, connectors, sequences string) {
+	report := "report:\n  driver: fs\n  config:\n    path: " + filepath.Join(dir, "report") + "\n"
+	buf := []byte(report + connectors + sequences)
+	if err := ioutil.WriteFile(filepath.Join(dir, "manopus.yaml"), buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func timerRunning(ctx context.Context, name string) bool {
+	event := &payload.Event{Input: "test", ID: "test"}
+	return output.Send(ctx, &payload.Response{Output: name, Request: event, Data: map[string]interface{}{"function": "timer", "duration": 60}}) != nil
+}
+
+func TestReload(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "manopus")
+	a.NoError(err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	sequences := `
+sequencer:
+  inputs: [first]
+  processor: starlark
+  sequences:
+    - name: hello
+      steps:
+        - name: start
+          script: respond('hello')
+`
+	writeConfig(t, dir, `
+stores:
+  main:
+    type: memory
+connectors:
+  first:
+    type: timer
+  second:
+    type: timer
+`, sequences)
+	c, s, _ := InitConfig(ctx, []string{dir}, true)
+	defer func() {
+		input.StopAll(ctx)
+		output.StopAll(ctx)
+	}()
+	a.True(timerRunning(ctx, "first"))
+	a.True(timerRunning(ctx, "second"))
+
+	//Invalid configuration is rejected
+	writeConfig(t, dir, `
+connectors:
+  first:
+    type: timer
+  third:
+    type: unknown
+`, sequences)
+	a.Error(Reload(ctx, []string{dir}, c))
+	a.True(timerRunning(ctx, "second"))
+	a.Equal("hello", s.Roll(ctx, &payload.Event{Input: "first", Data: map[string]interface{}{}}))
+
+	writeConfig(t, dir, `
+stores:
+  main:
+    type: memory
+    config:
+      changed: true
+connectors:
+  first:
+    type: timer
+  third:
+    type: timer
+`, `
+sequencer:
+  inputs: [first, third]
+  processor: starlark
+  sequences:
+    - name: hello
+      steps:
+        - name: start
+          script: respond('reloaded')
+`)
+	a.NoError(Reload(ctx, []string{dir}, c))
+	a.True(timerRunning(ctx, "first"))
+	a.False(timerRunning(ctx, "second"))
+	a.True(timerRunning(ctx, "third"))
+	a.Equal("reloaded", s.Roll(ctx, &payload.Event{Input: "third", Data: map[string]interface{}{}}))
+	//Changed store keeps running with the old configuration
+	a.Nil(c.Stores["main"].Config)
+
+	//Connectors are not changed if the sequencer rejects configuration
+	s.Stop(ctx)
+	writeConfig(t, dir, `
+connectors:
+  first:
+    type: timer
+`, sequences)
+	a.Error(Reload(ctx, []string{dir}, c))
+	a.True(timerRunning(ctx, "third"))
+}
+
+func TestReload_Endpoint(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "manopus")
+	a.NoError(err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !a.NoError(err) {
+		return
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+	sequences := `
+sequencer:
+  inputs: [web]
+  processor: starlark
+  sequences:
+    - name: hello
+      steps:
+        - name: start
+          script: respond('hello')
+`
+	server := "http:\n  listen: " + addr + "\nadmin:\n  token: secret\n"
+	writeConfig(t, dir, server+`
+connectors:
+  web:
+    type: http
+`, sequences)
+	c, s, h := InitConfig(ctx, []string{dir}, true)
+	defer func() {
+		s.Stop(ctx)
+		input.StopAll(ctx)
+		output.StopAll(ctx)
+		h.Stop(ctx)
+	}()
+	a.NotNil(c)
+
+	//Changed connector registers its HTTP handlers again while the reload request is served
+	writeConfig(t, dir, server+`
+connectors:
+  web:
+    type: http
+    config:
+      changed: true
+`, sequences)
+	client := &nethttp.Client{Timeout: 5 * time.Second}
+	reload := func() (*nethttp.Response, error) {
+		req, _ := nethttp.NewRequest(nethttp.MethodPost, "http://"+addr+"/admin/reload", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		return client.Do(req)
+	}
+	resp, err := reload()
+	for i := 0; err != nil && i < 50; i++ {
+		//Server is starting in background
+		time.Sleep(10 * time.Millisecond)
+		resp, err = reload()
+	}
+	if !a.NoError(err) {
+		return
+	}
+	_ = resp.Body.Close()
+	a.Equal(nethttp.StatusOK, resp.StatusCode)
+	a.Equal(true, c.Connectors["web"].Config["changed"])
+
+	//Router keeps serving requests after the reload
+	resp, err = client.Get("http://" + addr + "/metrics")
+	if a.NoError(err) {
+		_ = resp.Body.Close()
+		a.Equal(nethttp.StatusOK, resp.StatusCode)
+	}
+}
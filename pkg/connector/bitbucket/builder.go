@@ -10,6 +10,7 @@ import (
 	"github.com/geliar/manopus/pkg/input"
 	"github.com/geliar/manopus/pkg/log"
 	"github.com/geliar/manopus/pkg/output"
+	"github.com/geliar/manopus/pkg/payload"
 
 	cbitbucket "github.com/ktrysmt/go-bitbucket"
 	whbitbucket "gopkg.in/go-playground/webhooks.v5/bitbucket"
@@ -18,6 +19,9 @@ import (
 func init() {
 	ctx := log.Logger.WithContext(context.Background())
 	connector.Register(ctx, serviceName, builder)
+	payload.RegisterData(serviceName, requestTypePullRequestCreated, requestPullRequestCreated{})
+	payload.RegisterData(serviceName, requestTypePullRequestApproved, requestPullRequestApproved{})
+	payload.RegisterData(serviceName, requestTypeRepoPush, requestPush{})
 }
 
 func builder(ctx context.Context, name string, config map[string]interface{}) {
@@ -39,6 +43,7 @@ func builder(ctx context.Context, name string, config map[string]interface{}) {
 				l.Error().Err(err).Msg("Error applying Bitbucket UUID")
 			}
 			mhttp.AddHandler(ctx, callback, http.HandlerFunc(i.webhookHandler))
+			i.callback = callback
 			l.Info().Msgf("Bitbucket webhook on path %s", callback)
 		}
 		okey, _ := config["oauth2_key"].(string)
@@ -12,8 +12,10 @@ import (
 	"github.com/rs/zerolog/hlog"
 	whbitbucket "gopkg.in/go-playground/webhooks.v5/bitbucket"
 
+	mhttp "github.com/geliar/manopus/pkg/http"
 	"github.com/geliar/manopus/pkg/input"
 	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/trace"
 )
 
 //Bitbucket implementation of the Bitbucket connector
@@ -25,6 +27,7 @@ type Bitbucket struct {
 	stop     bool
 	stopCh   chan struct{}
 	mu       sync.RWMutex
+	callback string
 	hook     *whbitbucket.Webhook
 	client   *cbitbucket.Client
 }
@@ -94,6 +97,7 @@ func (c *Bitbucket) webhookHandler(w http.ResponseWriter, r *http.Request) {
 	event := new(payload.Event)
 	event.ID = c.getID()
 	event.Input = c.name
+	event.Trace = trace.FromRequest(r)
 	switch v := data.(type) {
 	case whbitbucket.PullRequestCreatedPayload:
 		event.Type = requestTypePullRequestCreated
@@ -126,6 +130,9 @@ func (c *Bitbucket) Stop(ctx context.Context) {
 	if !c.stop {
 		c.stop = true
 		close(c.stopCh)
+		if c.callback != "" {
+			mhttp.RemoveHandler(ctx, c.callback)
+		}
 	}
 }
 
@@ -7,6 +7,7 @@ import (
 
 type catalogStore struct {
 	connectors map[string]Builder
+	validators map[string]Validator
 	sync.RWMutex
 }
 
@@ -17,11 +18,41 @@ func Register(ctx context.Context, name string, driver Builder) {
 	catalog.register(ctx, name, driver)
 }
 
+// RegisterValidator registers validator of the configuration of the connector type in the catalog
+func RegisterValidator(ctx context.Context, name string, validator Validator) {
+	catalog.Lock()
+	defer catalog.Unlock()
+	if catalog.validators == nil {
+		catalog.validators = make(map[string]Validator)
+	}
+	catalog.validators[name] = validator
+}
+
+// Validate checks connector configuration with the validator of its type.
+// Configuration of the type without validator is always valid.
+func Validate(connector Config) error {
+	catalog.RLock()
+	validator, ok := catalog.validators[connector.Type]
+	catalog.RUnlock()
+	if !ok {
+		return nil
+	}
+	return validator(connector.Config)
+}
+
 // Configure register specified connector with configuration and connector builder from the catalog
 func Configure(ctx context.Context, name string, connector Config) {
 	catalog.configure(ctx, name, connector)
 }
 
+// Exists checks if connector builder with specified type is registered in the catalog
+func Exists(connectorType string) bool {
+	catalog.RLock()
+	defer catalog.RUnlock()
+	_, ok := catalog.connectors[connectorType]
+	return ok
+}
+
 func (c *catalogStore) register(ctx context.Context, name string, driver Builder) {
 	c.Lock()
 	defer c.Unlock()
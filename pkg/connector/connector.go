@@ -10,3 +10,6 @@ type Config struct {
 
 // Builder connector builder description
 type Builder func(ctx context.Context, name string, config map[string]interface{})
+
+// Validator checks connector configuration before the connector is built
+type Validator func(config map[string]interface{}) error
@@ -10,6 +10,7 @@ import (
 	"github.com/geliar/manopus/pkg/input"
 	"github.com/geliar/manopus/pkg/log"
 	"github.com/geliar/manopus/pkg/output"
+	"github.com/geliar/manopus/pkg/payload"
 
 	cgithub "github.com/google/go-github/v24/github"
 	whgithub "gopkg.in/go-playground/webhooks.v5/github"
@@ -20,6 +21,9 @@ import (
 func init() {
 	ctx := log.Logger.WithContext(context.Background())
 	connector.Register(ctx, serviceName, builder)
+	payload.RegisterData(serviceName, requestTypePullRequest, requestPullRequest{})
+	payload.RegisterData(serviceName, requestTypeIssueComment, requestIssueComment{})
+	payload.RegisterData(serviceName, requestTypePush, requestPush{})
 }
 
 func builder(ctx context.Context, name string, config map[string]interface{}) {
@@ -41,6 +45,7 @@ func builder(ctx context.Context, name string, config map[string]interface{}) {
 				l.Error().Err(err).Msg("Error applying GitHub secret")
 			}
 			mhttp.AddHandler(ctx, callback, http.HandlerFunc(i.webhookHandler))
+			i.callback = callback
 			l.Info().Msgf("GitHub webhook on path %s", callback)
 		}
 		token, _ := config["oauth2_token"].(string)
@@ -17,8 +17,10 @@ import (
 
 	"github.com/rs/zerolog/hlog"
 
+	mhttp "github.com/geliar/manopus/pkg/http"
 	"github.com/geliar/manopus/pkg/input"
 	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/trace"
 )
 
 // GitHub connector implementation
@@ -30,6 +32,7 @@ type GitHub struct {
 	stop     bool
 	stopCh   chan struct{}
 	mu       sync.RWMutex
+	callback string
 	hook     *whgithub.Webhook
 	client   *cgithub.Client
 }
@@ -255,6 +258,9 @@ func (c *GitHub) Stop(ctx context.Context) {
 	if !c.stop {
 		c.stop = true
 		close(c.stopCh)
+		if c.callback != "" {
+			mhttp.RemoveHandler(ctx, c.callback)
+		}
 	}
 }
 
@@ -274,6 +280,7 @@ func (c *GitHub) webhookHandler(w http.ResponseWriter, r *http.Request) {
 	event := new(payload.Event)
 	event.ID = c.getID()
 	event.Input = c.name
+	event.Trace = trace.FromRequest(r)
 	switch v := data.(type) {
 
 	case whgithub.PullRequestPayload:
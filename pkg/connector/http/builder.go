@@ -9,10 +9,13 @@ import (
 	"github.com/geliar/manopus/pkg/input"
 	"github.com/geliar/manopus/pkg/log"
 	"github.com/geliar/manopus/pkg/output"
+	"github.com/geliar/manopus/pkg/payload"
 )
 
 func init() {
 	connector.Register(log.Logger.WithContext(context.Background()), connectorName, builder)
+	payload.RegisterData(connectorName, requestTypeHTTPRequest, requestHTTPRequest{})
+	payload.RegisterData(connectorName, requestTypeHTTPJSONRequest, requestHTTPJSONRequest{})
 }
 
 func builder(ctx context.Context, name string, config map[string]interface{}) {
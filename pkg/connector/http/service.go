@@ -11,7 +11,9 @@ import (
 	"sync/atomic"
 
 	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/trace"
 
+	mhttp "github.com/geliar/manopus/pkg/http"
 	"github.com/geliar/manopus/pkg/input"
 )
 
@@ -59,6 +61,7 @@ func (c *HTTP) Send(ctx context.Context, response *payload.Response) map[string]
 // Stop connector
 func (c *HTTP) Stop(ctx context.Context) {
 	c.stop = true
+	mhttp.SetDefaultHandler(ctx, nil)
 }
 
 func (c *HTTP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -92,6 +95,7 @@ func (c *HTTP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			Headers:     r.Header,
 			Body:        string(buf),
 		},
+		Trace: trace.FromRequest(r),
 	}
 	switch r.Header.Get("Content-Type") {
 	case "application/json":
@@ -11,6 +11,7 @@ import (
 	"github.com/geliar/manopus/pkg/input"
 	"github.com/geliar/manopus/pkg/log"
 	"github.com/geliar/manopus/pkg/output"
+	"github.com/geliar/manopus/pkg/payload"
 
 	"github.com/nlopes/slack"
 	"github.com/rs/zerolog"
@@ -19,6 +20,8 @@ import (
 func init() {
 	ctx := log.Logger.WithContext(context.Background())
 	connector.Register(ctx, connectorName, builder)
+	payload.RegisterData(connectorName, requestTypeInteraction, requestInteraction{})
+	payload.RegisterData(connectorName, requestTypeMessage, requestMessage{})
 }
 
 type slackLogger struct {
@@ -67,8 +70,10 @@ func builder(ctx context.Context, name string, config map[string]interface{}) {
 
 	if eventCallback, _ := config["event_callback"].(string); eventCallback != "" {
 		mhttp.AddHandler(ctx, eventCallback, http.HandlerFunc(i.eventCallbackHandler))
+		i.callbacks = append(i.callbacks, eventCallback)
 	}
 	if interactionCallback, _ := config["interaction_callback"].(string); interactionCallback != "" {
 		mhttp.AddHandler(ctx, interactionCallback, http.HandlerFunc(i.interactionCallbackHandler))
+		i.callbacks = append(i.callbacks, interactionCallback)
 	}
 }
@@ -15,9 +15,11 @@ import (
 	"github.com/nlopes/slack/slackevents"
 	"github.com/rs/zerolog/hlog"
 
+	mhttp "github.com/geliar/manopus/pkg/http"
 	"github.com/geliar/manopus/pkg/input"
 	"github.com/geliar/manopus/pkg/log"
 	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/trace"
 )
 
 //Slack implementation of connector for Slack chat
@@ -40,8 +42,10 @@ type Slack struct {
 	}
 	rtm      *slack.RTM
 	handlers []input.Handler
-	stop     bool
-	stopped  chan struct{}
+	//callbacks HTTP paths of the connector handlers
+	callbacks []string
+	stop      bool
+	stopped   chan struct{}
 	sync.RWMutex
 }
 
@@ -128,6 +132,9 @@ func (c *Slack) Stop(ctx context.Context) {
 	}
 	c.stop = true
 	c.Unlock()
+	for _, path := range c.callbacks {
+		mhttp.RemoveHandler(ctx, path)
+	}
 	if c.config.rtm && c.rtm != nil {
 		_ = c.rtm.Disconnect()
 		<-c.stopped
@@ -196,6 +203,7 @@ func (c *Slack) interactionCallbackHandler(w http.ResponseWriter, r *http.Reques
 		Type:  requestTypeInteraction,
 		ID:    c.getID(),
 		Data:  data,
+		Trace: trace.FromRequest(r),
 	}
 
 	c.sendEventToHandlers(ctx, ev.Channel.ID, e)
@@ -295,6 +303,7 @@ func (c *Slack) eventCallbackHandler(w http.ResponseWriter, r *http.Request) {
 				Data:  data,
 			}
 		}
+		e.Trace = trace.FromRequest(r)
 		c.sendEventToHandlers(ctx, channel, e)
 	}
 }
@@ -373,8 +382,13 @@ func (c *Slack) rtmServe(ctx context.Context) {
 func (c *Slack) sendEventToHandlers(ctx context.Context, channel string, event *payload.Event) {
 	c.RLock()
 	defer c.RUnlock()
+	if event.Key == "" {
+		event.Key = orderingKey(event.Data, channel)
+	}
 	for _, h := range c.handlers {
+		ctx, accepted := input.WithAcceptance(ctx)
 		go func(handler input.Handler) {
+			defer input.Accept(ctx)
 			res := handler(ctx, event)
 			if res != nil {
 				response := new(payload.Response)
@@ -390,7 +404,24 @@ func (c *Slack) sendEventToHandlers(ctx context.Context, channel string, event *
 				c.Send(ctx, response)
 			}
 		}(h)
+		//Waiting for the handler to queue the event to keep order of the events
+		<-accepted
+	}
+}
+
+// orderingKey returns ordering key of the event: channel and thread of the message
+func orderingKey(data interface{}, channel string) string {
+	var thread string
+	switch d := data.(type) {
+	case requestMessage:
+		thread = d.ThreadTS
+	case requestInteraction:
+		thread = d.ThreadTS
+	}
+	if thread == "" {
+		return channel
 	}
+	return channel + "/" + thread
 }
 
 func (c *Slack) updateChannels(ctx context.Context) {
@@ -8,11 +8,22 @@ import (
 	"github.com/geliar/manopus/pkg/input"
 	"github.com/geliar/manopus/pkg/log"
 	"github.com/geliar/manopus/pkg/output"
+	"github.com/geliar/manopus/pkg/payload"
 )
 
 func init() {
 	ctx := log.Logger.WithContext(context.Background())
 	connector.Register(ctx, connectorName, builder)
+	connector.RegisterValidator(ctx, connectorName, validate)
+	payload.RegisterData(connectorName, requestTypeTicker, requestTicker{})
+	payload.RegisterData(connectorName, requestTypeTimer, requestTimer{})
+	payload.RegisterData(connectorName, requestTypeSchedule, requestSchedule{})
+}
+
+// validate checks schedules of the timer, so invalid ones reject the whole configuration
+func validate(config map[string]interface{}) error {
+	_, err := parseSchedules(config["schedules"])
+	return err
 }
 
 func builder(ctx context.Context, name string, config map[string]interface{}) {
@@ -24,12 +35,26 @@ func builder(ctx context.Context, name string, config map[string]interface{}) {
 	i.created = time.Now().UTC().UnixNano()
 	i.name = name
 	i.stopCh = make(chan struct{})
+	i.timers = make(map[string]*pendingTimer)
 	if config != nil {
 		ticker, _ := config["ticker"].(int)
 		if ticker > 0 {
 			go i.ticker(ctx, time.Duration(ticker)*time.Second)
 			l.Info().Msgf("Ticker will send event every %d seconds", ticker)
 		}
+		i.store, _ = config["store"].(string)
+		i.storeKey, _ = config["store_key"].(string)
+		if i.storeKey == "" {
+			i.storeKey = name
+		}
+		schedules, err := parseSchedules(config["schedules"])
+		if err != nil {
+			l.Error().Err(err).Msg("Cannot parse schedules of timer")
+		}
+		i.schedules = schedules
+		if len(schedules) > 0 {
+			l.Info().Msgf("Timer has %d schedules", len(schedules))
+		}
 	}
 	input.Register(ctx, name, i)
 	output.Register(ctx, name, i)
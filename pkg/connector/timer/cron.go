@@ -0,0 +1,189 @@
+package timer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule parsed cron expression. Every field is a bit set of the allowed values.
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	location                              *time.Location
+}
+
+// cronField describes range and names of the field of cron expression
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronSeconds = cronField{min: 0, max: 59}
+	cronMinutes = cronField{min: 0, max: 59}
+	cronHours   = cronField{min: 0, max: 23}
+	cronDoms    = cronField{min: 1, max: 31}
+	cronMonths  = cronField{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDows = cronField{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// cronMacros predefined cron expressions
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// starBit marks the field which has been specified with * to apply OR rule to day of month and day of week
+const starBit = 1 << 63
+
+// parseCron parses standard 5-field (minute hour dom month dow) or 6-field (second minute hour dom month dow)
+// cron expression or one of the macros (@hourly, @daily, @weekly, @monthly, @yearly)
+func parseCron(expression string, location *time.Location) (*cronSchedule, error) {
+	if location == nil {
+		location = time.UTC
+	}
+	if macro, ok := cronMacros[strings.ToLower(strings.TrimSpace(expression))]; ok {
+		expression = macro
+	}
+	fields := strings.Fields(expression)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron expression should have 5 or 6 fields, got %d", len(fields))
+	}
+	s := &cronSchedule{location: location}
+	var err error
+	for i, f := range []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&s.second, cronSeconds},
+		{&s.minute, cronMinutes},
+		{&s.hour, cronHours},
+		{&s.dom, cronDoms},
+		{&s.month, cronMonths},
+		{&s.dow, cronDows},
+	} {
+		if *f.bits, err = parseCronField(fields[i], f.field); err != nil {
+			return nil, fmt.Errorf("cron field '%s': %s", fields[i], err)
+		}
+	}
+	//Sunday can be specified as 0 or 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+func parseCronField(value string, field cronField) (bits uint64, err error) {
+	for _, part := range strings.Split(value, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("wrong step '%s'", part[i+1:])
+			}
+			part = part[:i]
+		}
+		from, to := field.min, field.max
+		switch {
+		case part == "*" || part == "?":
+			if step == 1 {
+				bits |= starBit
+			}
+		case strings.Contains(part, "-"):
+			i := strings.Index(part, "-")
+			if from, err = field.value(part[:i]); err != nil {
+				return 0, err
+			}
+			if to, err = field.value(part[i+1:]); err != nil {
+				return 0, err
+			}
+		default:
+			if from, err = field.value(part); err != nil {
+				return 0, err
+			}
+			if step == 1 {
+				to = from
+			}
+		}
+		if from > to {
+			return 0, fmt.Errorf("wrong range %d-%d", from, to)
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value parses number or name of the field value
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("wrong value '%s'", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d is out of range %d-%d", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first time after t which matches the schedule.
+// Returns zero time if there is no such time within 5 years.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	t = t.In(s.location).Truncate(time.Second).Add(time.Second)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location), time.Hour)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, s.location), time.Minute)
+		case s.second&(1<<uint(t.Second())) == 0:
+			t = t.Add(time.Second)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// forward returns the next candidate built from wall clock in the location of the schedule.
+// Wall clock is ambiguous when clocks are turned back, so step is added to t if the candidate is not after it.
+func forward(t, next time.Time, step time.Duration) time.Time {
+	if !next.After(t) {
+		return t.Add(step)
+	}
+	return next
+}
+
+// dayMatches checks day of month and day of week of t.
+// If both fields are restricted the day matches any of them like in standard cron.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.dom&starBit != 0 || s.dow&starBit != 0 {
+		return dom && dow
+	}
+	return dom || dow
+}
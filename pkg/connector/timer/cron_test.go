@@ -0,0 +1,69 @@
+package timer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	a := assert.New(t)
+	start := time.Date(2019, 4, 10, 10, 15, 30, 0, time.UTC) //Wednesday
+	tests := []struct {
+		expression string
+		next       time.Time
+	}{
+		{"* * * * *", time.Date(2019, 4, 10, 10, 16, 0, 0, time.UTC)},
+		{"*/10 * * * * *", time.Date(2019, 4, 10, 10, 15, 40, 0, time.UTC)},
+		{"0 9-17/4 * * mon-fri", time.Date(2019, 4, 10, 13, 0, 0, 0, time.UTC)},
+		{"30 8 * * 7", time.Date(2019, 4, 14, 8, 30, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2019, 4, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * fri", time.Date(2019, 4, 12, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 feb *", time.Date(2020, 2, 29, 12, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2019, 4, 10, 11, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		s, err := parseCron(test.expression, nil)
+		if a.NoError(err, test.expression) {
+			a.Equal(test.next, s.Next(start), test.expression)
+		}
+	}
+
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* * * * * * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		_, err := parseCron(expression, nil)
+		a.Error(err, expression)
+	}
+
+	zone := time.FixedZone("UTC+3", 3*60*60)
+	s, _ := parseCron("0 9 * * *", zone)
+	a.Equal(time.Date(2019, 4, 11, 6, 0, 0, 0, time.UTC), s.Next(start).UTC())
+
+	//Offset of the zone is not a whole hour
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	a.NoError(err)
+	s, _ = parseCron("0 9 * * *", kolkata)
+	a.Equal(time.Date(2019, 4, 11, 3, 30, 0, 0, time.UTC), s.Next(start).UTC())
+	s, _ = parseCron("*/15 * * * *", kolkata)
+	a.Equal(time.Date(2019, 4, 10, 10, 30, 0, 0, time.UTC), s.Next(start).UTC())
+}
+
+func TestSchedule_MissedRuns(t *testing.T) {
+	a := assert.New(t)
+	cron, _ := parseCron("0 * * * *", nil)
+	last := time.Date(2019, 4, 10, 10, 0, 0, 0, time.UTC)
+	now := time.Date(2019, 4, 10, 13, 30, 0, 0, time.UTC)
+	s := &schedule{cron: cron}
+
+	s.Missed = missedSkip
+	a.Empty(s.missedRuns(last, now))
+
+	s.Missed = missedOnce
+	a.Equal([]time.Time{time.Date(2019, 4, 10, 13, 0, 0, 0, time.UTC)}, s.missedRuns(last, now))
+
+	s.Missed = missedAll
+	a.Len(s.missedRuns(last, now), 3)
+	a.Len(s.missedRuns(last.AddDate(0, -1, 0), now), maxMissedRuns)
+	a.Empty(s.missedRuns(time.Time{}, now))
+}
@@ -0,0 +1,169 @@
+package timer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/geliar/manopus/pkg/payload"
+
+	"github.com/geliar/yaml"
+)
+
+const (
+	//missedSkip missed runs are not fired
+	missedSkip = "skip"
+	//missedOnce the latest missed run is fired once
+	missedOnce = "once"
+	//missedAll every missed run is fired
+	missedAll = "all"
+	//maxMissedRuns maximum number of missed runs fired with missed: all
+	maxMissedRuns = 100
+)
+
+// ScheduleConfig configuration of the named cron schedule
+type ScheduleConfig struct {
+	Name string `yaml:"name"`
+	//Cron 5-field or 6-field (with seconds) cron expression
+	Cron string `yaml:"cron"`
+	//Timezone name of time zone of the cron expression (UTC by default)
+	Timezone string `yaml:"timezone"`
+	//Missed how to handle runs missed during downtime: skip (default), once or all
+	Missed string `yaml:"missed"`
+}
+
+// schedule named cron schedule of the connector
+type schedule struct {
+	ScheduleConfig
+	cron *cronSchedule
+}
+
+// parseSchedules parses schedules section of the connector config
+func parseSchedules(config interface{}) ([]*schedule, error) {
+	if config == nil {
+		return nil, nil
+	}
+	//Nested maps are decoded as map[interface{}]interface{} so the section is decoded again into the structure
+	buf, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var configs []ScheduleConfig
+	if err = yaml.Unmarshal(buf, &configs); err != nil {
+		return nil, err
+	}
+	var schedules []*schedule
+	names := make(map[string]bool)
+	for _, sc := range configs {
+		if sc.Name == "" {
+			return nil, fmt.Errorf("schedule name cannot be empty")
+		}
+		if names[sc.Name] {
+			return nil, fmt.Errorf("schedule '%s' is defined twice", sc.Name)
+		}
+		names[sc.Name] = true
+		switch sc.Missed {
+		case "":
+			sc.Missed = missedSkip
+		case missedSkip, missedOnce, missedAll:
+		default:
+			return nil, fmt.Errorf("schedule '%s' has wrong missed value '%s'", sc.Name, sc.Missed)
+		}
+		location := time.UTC
+		if sc.Timezone != "" {
+			if location, err = time.LoadLocation(sc.Timezone); err != nil {
+				return nil, fmt.Errorf("schedule '%s': %s", sc.Name, err)
+			}
+		}
+		cron, err := parseCron(sc.Cron, location)
+		if err != nil {
+			return nil, fmt.Errorf("schedule '%s': %s", sc.Name, err)
+		}
+		schedules = append(schedules, &schedule{ScheduleConfig: sc, cron: cron})
+	}
+	return schedules, nil
+}
+
+// missedRuns returns planned times of the runs after last and not after now which should be fired
+func (s *schedule) missedRuns(last, now time.Time) (runs []time.Time) {
+	if last.IsZero() || s.Missed == missedSkip {
+		return nil
+	}
+	for next := s.cron.Next(last); !next.IsZero() && !next.After(now); next = s.cron.Next(next) {
+		runs = append(runs, next)
+		if s.Missed == missedOnce && len(runs) > 1 {
+			runs = runs[1:]
+		}
+		if len(runs) > maxMissedRuns {
+			runs = runs[1:]
+		}
+	}
+	return
+}
+
+// startSchedules fires missed runs and starts the schedules of the connector
+func (c *Timer) startSchedules(ctx context.Context, state timerState) {
+	l := logger(ctx)
+	now := time.Now()
+	for _, s := range c.schedules {
+		var last time.Time
+		if planned, ok := state.Schedules[s.Name]; ok {
+			last = time.Unix(planned, 0)
+		}
+		runs := s.missedRuns(last, now)
+		if len(runs) > 0 {
+			l.Info().
+				Str("schedule_name", s.Name).
+				Int("missed_runs", len(runs)).
+				Msg("Firing runs of schedule missed during downtime")
+		}
+		for _, planned := range runs {
+			c.fireSchedule(ctx, s, planned, true)
+		}
+		go c.runSchedule(ctx, s)
+	}
+}
+
+func (c *Timer) runSchedule(ctx context.Context, s *schedule) {
+	l := logger(ctx).With().Str("schedule_name", s.Name).Logger()
+	for {
+		next := s.cron.Next(time.Now())
+		if next.IsZero() {
+			l.Warn().Msg("Schedule has no next run")
+			return
+		}
+		l.Debug().Msgf("Next run of schedule is at %s", next)
+		t := time.NewTimer(time.Until(next))
+		select {
+		case <-c.stopCh:
+			t.Stop()
+			l.Info().Msg("Schedule has been stopped")
+			return
+		case <-t.C:
+			c.fireSchedule(ctx, s, next, false)
+		}
+	}
+}
+
+// fireSchedule sends schedule event and saves planned time of the run
+func (c *Timer) fireSchedule(ctx context.Context, s *schedule, planned time.Time, missed bool) {
+	id := c.getID()
+	c.sendEventToHandlers(ctx, &payload.Event{
+		Input: c.name,
+		Type:  requestTypeSchedule,
+		ID:    id,
+		Data: requestSchedule{
+			ScheduleID: id,
+			Schedule:   s.Name,
+			Planned:    planned.UTC().Unix(),
+			Now:        time.Now().UTC().Unix(),
+			Missed:     missed,
+		},
+	})
+	c.updateState(ctx, func(state *timerState) {
+		if state.Schedules == nil {
+			state.Schedules = make(map[string]int64)
+		}
+		state.Schedules[s.Name] = planned.Unix()
+	})
+}
@@ -22,6 +22,15 @@ type Timer struct {
 	stop     bool
 	stopCh   chan struct{}
 	mu       sync.RWMutex
+
+	schedules     []*schedule
+	startOnce     sync.Once
+	timers        map[string]*pendingTimer
+	timersStopped bool
+	timersMu      sync.Mutex
+	store         string
+	storeKey      string
+	stateMu       sync.Mutex
 }
 
 // Name returns name of the connector
@@ -37,8 +46,10 @@ func (c *Timer) Type() string {
 // RegisterHandler registers event handler with connector
 func (c *Timer) RegisterHandler(ctx context.Context, handler input.Handler) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
 	c.handlers = append(c.handlers, handler)
+	c.mu.Unlock()
+	//Timers and schedules are started with the first handler so overdue timers and missed runs are not lost
+	c.startOnce.Do(func() { c.start(ctx) })
 }
 
 // Send sends response with connector
@@ -75,24 +86,26 @@ func (c *Timer) Send(ctx context.Context, response *payload.Response) map[string
 			l.Error().Msg("duration field is empty")
 			return nil
 		}
-		id := c.getID()
-		dur := time.Duration(d) * time.Second
-		time.AfterFunc(dur, func() {
-			data, _ := response.Data["data"].(map[string]interface{})
-			c.sendEventToHandlers(ctx, &payload.Event{
-				Input: c.name,
-				Type:  requestTypeTimer,
-				ID:    id,
-				Data: requestTimer{
-					TimerID: id,
-					Now:     time.Now().UTC().Unix(),
-					Data:    data,
-				},
-			})
-		})
+		data, _ := response.Data["data"].(map[string]interface{})
+		t := c.addTimer(ctx, d*time.Second, data)
 		return map[string]interface{}{
-			"timer_id": id,
+			"timer_id": t.ID,
 			"now":      time.Now().UTC().Unix(),
+			"deadline": t.Deadline,
+		}
+	case "cancel_timer":
+		id, _ := response.Data["timer_id"].(string)
+		if id == "" {
+			l.Error().Msg("timer_id field is empty")
+			return nil
+		}
+		return map[string]interface{}{
+			"timer_id":  id,
+			"cancelled": c.removeTimer(ctx, id),
+		}
+	case "list_timers":
+		return map[string]interface{}{
+			"timers": c.listTimers(),
 		}
 	}
 	return nil
@@ -103,6 +116,7 @@ func (c *Timer) Stop(ctx context.Context) {
 	if !c.stop {
 		c.stop = true
 		close(c.stopCh)
+		c.stopTimers()
 	}
 }
 
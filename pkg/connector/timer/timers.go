@@ -0,0 +1,192 @@
+package timer
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/store"
+)
+
+// timerState persisted state of the connector
+type timerState struct {
+	//Schedules planned time of the last fired run of every schedule
+	Schedules map[string]int64 `json:"schedules"`
+	//Timers pending one-shot timers
+	Timers map[string]*pendingTimer `json:"timers"`
+}
+
+// pendingTimer one-shot timer which has not fired yet
+type pendingTimer struct {
+	ID string `json:"id"`
+	//Deadline unix time when the timer fires
+	Deadline int64                  `json:"deadline"`
+	Data     map[string]interface{} `json:"data"`
+	timer    *time.Timer
+}
+
+// start re-arms persisted timers and starts the schedules of the connector
+func (c *Timer) start(ctx context.Context) {
+	l := logger(ctx)
+	state := c.loadState(ctx)
+	if len(state.Timers) > 0 {
+		l.Info().Int("timers", len(state.Timers)).Msg("Re-arming persisted timers")
+	}
+	c.timersMu.Lock()
+	for id, t := range state.Timers {
+		if _, ok := c.timers[id]; ok {
+			continue
+		}
+		c.timers[id] = t
+		c.arm(ctx, t)
+	}
+	c.timersMu.Unlock()
+	c.startSchedules(ctx, state)
+}
+
+// addTimer arms the new timer and persists it
+func (c *Timer) addTimer(ctx context.Context, duration time.Duration, data map[string]interface{}) *pendingTimer {
+	deadline := time.Now().Add(duration)
+	t := &pendingTimer{
+		ID: c.getID(),
+		//Deadline is rounded up to the second so the timer never fires earlier than requested
+		Deadline: deadline.Add(time.Second - 1).Unix(),
+		Data:     data,
+	}
+	c.timersMu.Lock()
+	c.timers[t.ID] = t
+	c.arm(ctx, t)
+	c.timersMu.Unlock()
+	c.updateState(ctx, func(state *timerState) {
+		if state.Timers == nil {
+			state.Timers = make(map[string]*pendingTimer)
+		}
+		state.Timers[t.ID] = t
+	})
+	return t
+}
+
+// arm starts the timer. Overdue timer fires immediately. Should be called with timersMu locked.
+func (c *Timer) arm(ctx context.Context, t *pendingTimer) {
+	if c.timersStopped {
+		return
+	}
+	t.timer = time.AfterFunc(time.Until(time.Unix(t.Deadline, 0)), func() {
+		c.timersMu.Lock()
+		_, ok := c.timers[t.ID]
+		c.timersMu.Unlock()
+		if !ok {
+			return
+		}
+		c.sendEventToHandlers(ctx, &payload.Event{
+			Input: c.name,
+			Type:  requestTypeTimer,
+			ID:    t.ID,
+			Data: requestTimer{
+				TimerID: t.ID,
+				Now:     time.Now().UTC().Unix(),
+				Data:    t.Data,
+			},
+		})
+		//Timer is removed after the event has been sent so it fires again after crash during sending
+		c.removeTimer(ctx, t.ID)
+	})
+}
+
+// removeTimer stops and removes the timer. Returns false if there is no such timer.
+func (c *Timer) removeTimer(ctx context.Context, id string) bool {
+	c.timersMu.Lock()
+	t, ok := c.timers[id]
+	if ok && t.timer != nil {
+		t.timer.Stop()
+	}
+	if ok {
+		delete(c.timers, id)
+	}
+	c.timersMu.Unlock()
+	if !ok {
+		return false
+	}
+	c.updateState(ctx, func(state *timerState) {
+		delete(state.Timers, id)
+	})
+	return true
+}
+
+// listTimers returns pending timers ordered by deadline
+func (c *Timer) listTimers() []interface{} {
+	c.timersMu.Lock()
+	timers := make([]*pendingTimer, 0, len(c.timers))
+	for _, t := range c.timers {
+		timers = append(timers, t)
+	}
+	c.timersMu.Unlock()
+	sort.Slice(timers, func(i, j int) bool {
+		if timers[i].Deadline == timers[j].Deadline {
+			return timers[i].ID < timers[j].ID
+		}
+		return timers[i].Deadline < timers[j].Deadline
+	})
+	list := make([]interface{}, 0, len(timers))
+	for _, t := range timers {
+		list = append(list, map[string]interface{}{
+			"timer_id": t.ID,
+			"deadline": t.Deadline,
+			"data":     t.Data,
+		})
+	}
+	return list
+}
+
+// stopTimers stops pending timers. Persisted timers are re-armed on the next start.
+func (c *Timer) stopTimers() {
+	c.timersMu.Lock()
+	defer c.timersMu.Unlock()
+	c.timersStopped = true
+	for _, t := range c.timers {
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+	}
+}
+
+func (c *Timer) loadState(ctx context.Context) (state timerState) {
+	if c.store == "" {
+		return
+	}
+	l := logger(ctx)
+	buf, err := store.Load(ctx, c.store, c.storeKey)
+	if err != nil {
+		l.Error().Err(err).Msg("Cannot load state of timer from store")
+		return
+	}
+	if len(buf) == 0 {
+		return
+	}
+	if err = json.Unmarshal(buf, &state); err != nil {
+		l.Error().Err(err).Msg("Cannot decode state of timer")
+	}
+	return
+}
+
+// updateState applies the change to the persisted state of the connector
+func (c *Timer) updateState(ctx context.Context, change func(state *timerState)) {
+	if c.store == "" {
+		return
+	}
+	l := logger(ctx)
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	state := c.loadState(ctx)
+	change(&state)
+	buf, err := json.Marshal(state)
+	if err != nil {
+		l.Error().Err(err).Msg("Cannot encode state of timer")
+		return
+	}
+	if err = store.Save(ctx, c.store, c.storeKey, buf); err != nil {
+		l.Error().Err(err).Msg("Cannot save state of timer to store")
+	}
+}
@@ -0,0 +1,70 @@
+package timer
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/store"
+	_ "github.com/geliar/manopus/pkg/store/memory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testTimer(ctx context.Context, events chan *payload.Event) *Timer {
+	c := &Timer{
+		name:     "timer",
+		stopCh:   make(chan struct{}),
+		timers:   make(map[string]*pendingTimer),
+		store:    "timers",
+		storeKey: "timer",
+	}
+	c.RegisterHandler(ctx, func(ctx context.Context, event *payload.Event) interface{} {
+		events <- event
+		return nil
+	})
+	return c
+}
+
+func TestTimer_Persistent(t *testing.T) {
+	a := assert.New(t)
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	if !store.Exists("timers") {
+		store.ConfigureStore(ctx, "timers", store.Config{Type: "memory"})
+	}
+	a.NoError(store.Save(ctx, "timers", "timer", nil))
+	events := make(chan *payload.Event, 10)
+	send := func(c *Timer, data map[string]interface{}) map[string]interface{} {
+		return c.Send(ctx, &payload.Response{Request: &payload.Event{}, Data: data})
+	}
+
+	c := testTimer(ctx, events)
+	first := send(c, map[string]interface{}{"function": "timer", "duration": 1, "data": map[string]interface{}{"n": "first"}})
+	second := send(c, map[string]interface{}{"function": "timer", "duration": 3600})
+	third := send(c, map[string]interface{}{"function": "timer", "duration": 7200})
+	a.Len(send(c, map[string]interface{}{"function": "list_timers"})["timers"], 3)
+	a.Equal(true, send(c, map[string]interface{}{"function": "cancel_timer", "timer_id": third["timer_id"]})["cancelled"])
+	a.Equal(false, send(c, map[string]interface{}{"function": "cancel_timer", "timer_id": third["timer_id"]})["cancelled"])
+	//Restart of the connector before the first timer fires
+	c.Stop(ctx)
+
+	//Overdue timer fires right after the start
+	time.Sleep(1100 * time.Millisecond)
+	c = testTimer(ctx, events)
+	defer c.Stop(ctx)
+	select {
+	case event := <-events:
+		a.Equal(first["timer_id"], event.ID)
+		a.Equal("first", event.Data.(requestTimer).Data["n"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("persisted timer has not fired")
+	}
+	timers := send(c, map[string]interface{}{"function": "list_timers"})["timers"].([]interface{})
+	if a.Len(timers, 1) {
+		a.Equal(second["timer_id"], timers[0].(map[string]interface{})["timer_id"])
+	}
+}
@@ -14,3 +14,13 @@ type requestTimer struct {
 	Now     int64                  `json:"now"`
 	Data    map[string]interface{} `json:"json"`
 }
+
+const requestTypeSchedule = "schedule"
+
+type requestSchedule struct {
+	ScheduleID string `json:"schedule_id"`
+	Schedule   string `json:"schedule"`
+	Planned    int64  `json:"planned"`
+	Now        int64  `json:"now"`
+	Missed     bool   `json:"missed"`
+}
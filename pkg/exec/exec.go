@@ -6,15 +6,23 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"syscall"
 
 	"github.com/geliar/manopus/pkg/report"
+	"github.com/geliar/manopus/pkg/trace"
 )
 
 // Exec executes console command, puts result into report and returns it to requester
 func Exec(ctx context.Context, reporter report.Driver, name string, arg ...string) (result int, stdoutResult, stderrResult string) {
 	l := logger(ctx)
+	ctx, span := trace.Start(ctx, "exec")
+	span.SetAttr("command", name)
+	defer func() {
+		span.SetAttr("exit_code", strconv.Itoa(result))
+		span.End()
+	}()
 
 	cmd := exec.CommandContext(ctx, name, arg...)
 	s := bytes.NewBufferString("")
@@ -47,6 +55,7 @@ func Exec(ctx context.Context, reporter report.Driver, name string, arg ...strin
 	err = cmd.Start()
 	if err != nil {
 		l.Error().Err(err).Msg("Cannot start the script")
+		span.SetError(err)
 		return 1, "", ""
 	}
 
@@ -55,6 +64,7 @@ func Exec(ctx context.Context, reporter report.Driver, name string, arg ...strin
 		exitErr, ok := err.(*exec.ExitError)
 		if !ok {
 			l.Error().Err(err).Msg("Error when executing script")
+			span.SetError(err)
 			return 1, "", ""
 		}
 		return exitErr.Sys().(syscall.WaitStatus).ExitStatus(), stdoutBuf.String(), stderrBuf.String()
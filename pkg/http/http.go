@@ -43,6 +43,11 @@ func AddHandler(ctx context.Context, path string, h http.Handler) {
 	server.AddHandler(ctx, path, h)
 }
 
+// RemoveHandler removes http.Handler from the router on specific path
+func RemoveHandler(ctx context.Context, path string) {
+	server.RemoveHandler(ctx, path)
+}
+
 // SetDefaultHandler sets default http.Handler for all unspecified paths
 func SetDefaultHandler(ctx context.Context, h http.Handler) {
 	server.SetDefaultHandler(ctx, h)
@@ -98,6 +103,18 @@ func (s *Server) AddHandler(ctx context.Context, path string, h http.Handler) {
 	l.Debug().Msg("Added HTTP server handler")
 }
 
+// RemoveHandler removes http.Handler from the router on specific path
+func (s *Server) RemoveHandler(ctx context.Context, path string) {
+	l := logger(ctx).With().Str("http_path", path).Logger()
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.routes[path]; !ok {
+		return
+	}
+	delete(s.routes, path)
+	l.Debug().Msg("Removed HTTP server handler")
+}
+
 // SetDefaultHandler sets default http.Handler for all unspecified paths
 func (s *Server) SetDefaultHandler(ctx context.Context, h http.Handler) {
 	l := logger(ctx)
@@ -110,15 +127,21 @@ func (s *Server) SetDefaultHandler(ctx context.Context, h http.Handler) {
 func (s *Server) routerHandler(w http.ResponseWriter, r *http.Request) {
 	hlog.FromRequest(r).Debug().
 		Msgf("%s %s", r.Method, r.RequestURI)
+	route, h := s.route(r.RequestURI)
+	if h != nil {
+		serve(route, h, w, r)
+	}
+}
+
+// route returns the route and the handler for the request URI. The handler is served
+// without holding the lock, so handlers can add and remove routes.
+func (s *Server) route(uri string) (string, http.Handler) {
 	s.RLock()
 	defer s.RUnlock()
 	for k, v := range s.routes {
-		if strings.HasPrefix(r.RequestURI, k) {
-			v.ServeHTTP(w, r)
-			return
+		if strings.HasPrefix(uri, k) {
+			return k, v
 		}
 	}
-	if s.defaultRoute != nil {
-		s.defaultRoute.ServeHTTP(w, r)
-	}
+	return "default", s.defaultRoute
 }
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/geliar/manopus/pkg/metrics"
+	"github.com/geliar/manopus/pkg/trace"
+)
+
+var metricRequestDuration = metrics.NewHistogram("manopus_http_request_duration_seconds",
+	"Time spent by the HTTP handlers.", nil, "route", "method", "code")
+
+// statusRecorder remembers status code written by the handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(buf []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(buf)
+}
+
+// Flush sends buffered data to the client if the underlying writer supports it
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// serve runs the handler of the route, records its latency and traces the request.
+// The span of the request joins the trace from traceparent header of the request.
+func serve(route string, h http.Handler, w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx, span := trace.Start(trace.WithRemote(r.Context(), r.Header.Get(trace.Header)), "http "+r.Method+" "+route)
+	defer span.End()
+	rec := &statusRecorder{ResponseWriter: w}
+	h.ServeHTTP(rec, r.WithContext(ctx))
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	span.SetAttr("http.method", r.Method)
+	span.SetAttr("http.route", route)
+	span.SetAttr("http.status_code", strconv.Itoa(rec.status))
+	metricRequestDuration.Since(start, route, r.Method, strconv.Itoa(rec.status))
+}
@@ -0,0 +1,30 @@
+package input
+
+import (
+	"context"
+	"sync"
+)
+
+type acceptanceKey struct{}
+
+// acceptance notifies the input that the handler has accepted the event
+type acceptance struct {
+	accepted chan struct{}
+	once     sync.Once
+}
+
+// WithAcceptance returns context for the handler and channel which is closed when the handler accepts the event.
+// Inputs which run handlers in background use it to pass the next event only after the previous one has been
+// accepted, so events keep their order. Handler which does not call Accept accepts the event when it returns.
+func WithAcceptance(ctx context.Context) (context.Context, <-chan struct{}) {
+	a := &acceptance{accepted: make(chan struct{})}
+	return context.WithValue(ctx, acceptanceKey{}, a), a.accepted
+}
+
+// Accept notifies the input that the event has been accepted for processing (queued or rejected).
+// It does nothing if the context has no acceptance or the event has already been accepted.
+func Accept(ctx context.Context) {
+	if a, ok := ctx.Value(acceptanceKey{}).(*acceptance); ok {
+		a.once.Do(func() { close(a.accepted) })
+	}
+}
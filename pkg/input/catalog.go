@@ -17,11 +17,31 @@ func Register(ctx context.Context, name string, driver Driver) {
 	catalog.register(ctx, name, driver)
 }
 
+// Unregister stops input driver and removes it from the catalog of inputs
+func Unregister(ctx context.Context, name string) {
+	catalog.unregister(ctx, name)
+}
+
+// RegisterHandler register handler in the input with specified name
+func RegisterHandler(ctx context.Context, name string, handler Handler) {
+	catalog.registerHandler(ctx, name, handler)
+}
+
 // RegisterHandlerAll register handler in all inputs
 func RegisterHandlerAll(ctx context.Context, handler Handler) {
 	catalog.registerHandlerAll(ctx, handler)
 }
 
+// Type returns type of the input driver with specified name or empty string if there is no such driver
+func Type(name string) string {
+	catalog.RLock()
+	defer catalog.RUnlock()
+	if driver, ok := catalog.inputs[name]; ok {
+		return driver.Type()
+	}
+	return ""
+}
+
 // StopAll stops all inputs
 func StopAll(ctx context.Context) {
 	catalog.stopAll(ctx)
@@ -49,13 +69,44 @@ func (c *catalogStore) register(ctx context.Context, name string, driver Driver)
 		Msg("Registered new input driver")
 }
 
+func (c *catalogStore) unregister(ctx context.Context, name string) {
+	c.Lock()
+	defer c.Unlock()
+	l := logger(ctx).With().Str("input_driver_name", name).Logger()
+	driver, ok := c.inputs[name]
+	if !ok {
+		l.Warn().Msg("Trying to unregister not existing input driver")
+		return
+	}
+	l.Info().
+		Str("input_driver_type", driver.Type()).
+		Msg("Shutting down input driver")
+	driver.Stop(ctx)
+	delete(c.inputs, name)
+}
+
+func (c *catalogStore) registerHandler(ctx context.Context, name string, handler Handler) {
+	c.RLock()
+	defer c.RUnlock()
+	l := logger(ctx).With().Str("input_driver_name", name).Logger()
+	driver, ok := c.inputs[name]
+	if !ok {
+		l.Error().Msgf("Cannot find input driver with name '%s'", name)
+		return
+	}
+	driver.RegisterHandler(ctx, instrument(name, handler))
+	l.Debug().
+		Str("input_driver_type", driver.Type()).
+		Msgf("Registered handler to input")
+}
+
 func (c *catalogStore) registerHandlerAll(ctx context.Context, handler Handler) {
 	c.RLock()
 	defer c.RUnlock()
 	l := logger(ctx)
 
 	for i := range c.inputs {
-		c.inputs[i].RegisterHandler(ctx, handler)
+		c.inputs[i].RegisterHandler(ctx, instrument(i, handler))
 		l.Debug().
 			Str("input_driver_name", c.inputs[i].Name()).
 			Str("input_driver_type", c.inputs[i].Type()).
@@ -0,0 +1,33 @@
+package input
+
+import (
+	"context"
+	"time"
+
+	"github.com/geliar/manopus/pkg/metrics"
+	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/trace"
+)
+
+var (
+	metricEvents = metrics.NewCounter("manopus_input_events_total",
+		"Number of events received from the input.", "input", "type")
+	metricHandlerDuration = metrics.NewHistogram("manopus_input_handler_duration_seconds",
+		"Time spent by the handlers processing events of the input.", nil, "input")
+)
+
+// instrument wraps handler of the input with metrics and tracing.
+// The span of the handling joins the trace of the event and becomes the parent of the further processing.
+func instrument(name string, handler Handler) Handler {
+	return func(ctx context.Context, event *payload.Event) interface{} {
+		metricEvents.Inc(name, event.Type)
+		defer metricHandlerDuration.Since(time.Now(), name)
+		ctx, span := trace.Start(trace.WithRemote(ctx, event.Trace), "input")
+		defer span.End()
+		span.SetAttr("input", name)
+		span.SetAttr("event_type", event.Type)
+		span.SetAttr("event_id", event.ID)
+		event.Trace = trace.Traceparent(ctx)
+		return handler(ctx, event)
+	}
+}
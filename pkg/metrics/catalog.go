@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"io"
+	"sort"
+	"sync"
+)
+
+type catalogStore struct {
+	families map[string]*family
+	sync.RWMutex
+}
+
+var catalog catalogStore
+
+// Write writes all registered metrics to w in Prometheus text format
+func Write(w io.Writer) error {
+	return catalog.write(w)
+}
+
+func (c *catalogStore) register(name, help, kind string, labels []string) *family {
+	c.Lock()
+	defer c.Unlock()
+	if c.families == nil {
+		c.families = make(map[string]*family)
+	}
+	if f, ok := c.families[name]; ok && f.kind == kind {
+		return f
+	}
+	f := &family{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		samples: make(map[string]*sample),
+	}
+	c.families[name] = f
+	return f
+}
+
+func (c *catalogStore) write(w io.Writer) error {
+	c.RLock()
+	var families []*family
+	for _, f := range c.families {
+		families = append(families, f)
+	}
+	c.RUnlock()
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+	for _, f := range families {
+		if err := f.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Handler returns http.Handler which exposes registered metrics in Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := logger(r.Context())
+		var buf bytes.Buffer
+		if err := Write(&buf); err != nil {
+			l.Error().Err(err).Msg("Cannot write metrics")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write(buf.Bytes())
+	})
+}
+
+func (f *family) write(w io.Writer) error {
+	f.Lock()
+	defer f.Unlock()
+	if len(f.samples) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(f.samples))
+	for k := range f.samples {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := f.samples[k]
+		if f.kind == kindHistogram {
+			if err := f.writeHistogram(w, s); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", f.name, formatLabels(f.labels, s.labelValues), formatValue(s.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeHistogram writes cumulative buckets, sum and count of the histogram sample
+func (f *family) writeHistogram(w io.Writer, s *sample) error {
+	var cumulative uint64
+	for i, bound := range f.buckets {
+		if s.counts != nil {
+			cumulative += s.counts[i]
+		}
+		labels := formatLabels(f.labels, s.labelValues, "le", formatValue(bound))
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, labels, cumulative); err != nil {
+			return err
+		}
+	}
+	labels := formatLabels(f.labels, s.labelValues)
+	_, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+		f.name, formatLabels(f.labels, s.labelValues, "le", "+Inf"), s.count,
+		f.name, labels, formatValue(s.value),
+		f.name, labels, s.count)
+	return err
+}
+
+// formatLabels formats label names and values. Extra label can be passed as name and value pair
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, names[i], labelEscaper.Replace(values[i])))
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[0], labelEscaper.Replace(extra[1])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
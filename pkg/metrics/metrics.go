@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// DefaultBuckets default upper bounds of the histogram buckets suitable for durations in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counter is the metric which value only increases
+type Counter struct {
+	*family
+}
+
+// Gauge is the metric which value can go up and down
+type Gauge struct {
+	*family
+}
+
+// Histogram is the metric which counts observed values in buckets
+type Histogram struct {
+	*family
+}
+
+// family contains all samples of the metric with the same name
+type family struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	samples map[string]*sample
+	sync.Mutex
+}
+
+type sample struct {
+	labelValues []string
+	//value value of the counter or gauge, sum of the observed values of the histogram
+	value float64
+	//counts number of the observed values in every bucket of the histogram (not cumulative)
+	counts []uint64
+	count  uint64
+}
+
+// NewCounter creates counter with specified label names and registers it in the catalog
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{family: catalog.register(name, help, kindCounter, labels)}
+}
+
+// NewGauge creates gauge with specified label names and registers it in the catalog
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{family: catalog.register(name, help, kindGauge, labels)}
+}
+
+// NewHistogram creates histogram with specified upper bounds of the buckets and label names
+// and registers it in the catalog. DefaultBuckets are used if buckets are empty
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	f := catalog.register(name, help, kindHistogram, labels)
+	f.Lock()
+	if f.buckets == nil {
+		f.buckets = append([]float64(nil), buckets...)
+		sort.Float64s(f.buckets)
+	}
+	f.Unlock()
+	return &Histogram{family: f}
+}
+
+// Observe adds value to the histogram with specified label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.Lock()
+	defer h.Unlock()
+	s := h.sample(labelValues)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets))
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.value += value
+}
+
+// Since observes seconds elapsed since start, e.g. defer h.Since(time.Now(), labels...)
+func (h *Histogram) Since(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// Inc increments counter with specified label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.add(1, labelValues)
+}
+
+// Add adds positive value to counter with specified label values
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	c.add(value, labelValues)
+}
+
+// Set sets value of the gauge with specified label values
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.Lock()
+	defer g.Unlock()
+	g.sample(labelValues).value = value
+}
+
+// Inc increments gauge with specified label values
+func (g *Gauge) Inc(labelValues ...string) {
+	g.add(1, labelValues)
+}
+
+// Dec decrements gauge with specified label values
+func (g *Gauge) Dec(labelValues ...string) {
+	g.add(-1, labelValues)
+}
+
+// Add adds value to gauge with specified label values
+func (g *Gauge) Add(value float64, labelValues ...string) {
+	g.add(value, labelValues)
+}
+
+func (f *family) add(value float64, labelValues []string) {
+	f.Lock()
+	defer f.Unlock()
+	f.sample(labelValues).value += value
+}
+
+// sample returns sample with specified label values, creates new one if it does not exist.
+// Warning: sample is not thread-safe family should be locked before use
+func (f *family) sample(labelValues []string) *sample {
+	values := make([]string, len(f.labels))
+	copy(values, labelValues)
+	key := strings.Join(values, "\xff")
+	s, ok := f.samples[key]
+	if !ok {
+		s = &sample{labelValues: values}
+		f.samples[key] = s
+	}
+	return s
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	a := assert.New(t)
+	c := NewCounter("test_events_total", "Number of test events.", "input", "type")
+	g := NewGauge("test_waiting", "Number of waiting tests.")
+	c.Inc("slack", "message")
+	c.Add(2, "slack", "message")
+	c.Inc(`quo"te`, "line\nbreak")
+	g.Set(5)
+	g.Dec()
+	var buf bytes.Buffer
+	a.NoError(Write(&buf))
+	a.Equal(`# HELP test_events_total Number of test events.
+# TYPE test_events_total counter
+test_events_total{input="quo\"te",type="line\nbreak"} 1
+test_events_total{input="slack",type="message"} 3
+# HELP test_waiting Number of waiting tests.
+# TYPE test_waiting gauge
+test_waiting 4
+`, buf.String())
+}
+
+func TestHistogram(t *testing.T) {
+	a := assert.New(t)
+	h := NewHistogram("test_duration_seconds", "Duration of the tests.", []float64{1, 0.1}, "test")
+	h.Observe(0.05, "a")
+	h.Observe(0.5, "a")
+	h.Observe(0.7, "a")
+	h.Observe(3, "a")
+	var buf bytes.Buffer
+	a.NoError(h.write(&buf))
+	a.Equal(`# HELP test_duration_seconds Duration of the tests.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{test="a",le="0.1"} 1
+test_duration_seconds_bucket{test="a",le="1"} 3
+test_duration_seconds_bucket{test="a",le="+Inf"} 4
+test_duration_seconds_sum{test="a"} 4.25
+test_duration_seconds_count{test="a"} 4
+`, buf.String())
+}
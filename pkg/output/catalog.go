@@ -2,9 +2,12 @@ package output
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/trace"
 )
 
 type catalogStore struct {
@@ -24,6 +27,11 @@ func Send(ctx context.Context, response *payload.Response) map[string]interface{
 	return catalog.send(ctx, response)
 }
 
+// Unregister stops output driver and removes it from the catalog
+func Unregister(ctx context.Context, name string) {
+	catalog.unregister(ctx, name)
+}
+
 // StopAll stop all outputs
 func StopAll(ctx context.Context) {
 	catalog.stopAll(ctx)
@@ -52,17 +60,46 @@ func (c *catalogStore) register(ctx context.Context, name string, driver Driver)
 }
 
 func (c *catalogStore) send(ctx context.Context, response *payload.Response) map[string]interface{} {
+	ctx, span := trace.Start(ctx, "output.send")
+	defer span.End()
+	span.SetAttr("output", response.Output)
 	c.RLock()
 	l := logger(ctx).With().Str("output_driver_name", response.Output).Logger()
+	metricSends.Inc(response.Output)
 	if _, ok := c.outputs[response.Output]; !ok {
 		c.RUnlock()
+		metricErrors.Inc(response.Output)
+		span.SetError(fmt.Errorf("cannot find output driver with name '%s'", response.Output))
 		l.Error().
 			Msgf("Cannot find output driver with name '%s'", response.Output)
 		return nil
 	}
 	o := c.outputs[response.Output]
 	c.RUnlock()
-	return o.Send(l.WithContext(ctx), response)
+	start := time.Now()
+	result := o.Send(l.WithContext(ctx), response)
+	metricSendDuration.Since(start, response.Output)
+	if failed(result) {
+		metricErrors.Inc(response.Output)
+		span.SetError(fmt.Errorf("output failed to send response: %v", result["error"]))
+	}
+	return result
+}
+
+func (c *catalogStore) unregister(ctx context.Context, name string) {
+	c.Lock()
+	defer c.Unlock()
+	l := logger(ctx).With().Str("output_driver_name", name).Logger()
+	driver, ok := c.outputs[name]
+	if !ok {
+		l.Warn().Msg("Trying to unregister not existing output driver")
+		return
+	}
+	l.Info().
+		Str("output_driver_type", driver.Type()).
+		Msg("Shutting down output driver")
+	driver.Stop(ctx)
+	delete(c.outputs, name)
 }
 
 func (c *catalogStore) stopAll(ctx context.Context) {
@@ -0,0 +1,22 @@
+package output
+
+import (
+	"github.com/geliar/manopus/pkg/metrics"
+)
+
+var (
+	metricSends = metrics.NewCounter("manopus_output_sends_total",
+		"Number of responses sent to the output.", "output")
+	metricErrors = metrics.NewCounter("manopus_output_errors_total",
+		"Number of responses the output failed to send.", "output")
+	metricSendDuration = metrics.NewHistogram("manopus_output_send_duration_seconds",
+		"Time spent sending responses to the output.", nil, "output")
+)
+
+// failed checks result of the output driver. Drivers report failure with false result or error field
+func failed(result map[string]interface{}) bool {
+	if ok, isBool := result["result"].(bool); isBool && !ok {
+		return true
+	}
+	return result["error"] != nil
+}
@@ -0,0 +1,60 @@
+package payload
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// dataTypes registry of the types of the event data
+var dataTypes struct {
+	types map[dataKey]reflect.Type
+	sync.RWMutex
+}
+
+type dataKey struct {
+	inputType string
+	eventType string
+}
+
+// RegisterData registers type of the data of the events with specified type
+// which are produced by the inputs of specified type.
+// Registered type is used to decode JSON encoded data back to the same type.
+func RegisterData(inputType, eventType string, data interface{}) {
+	dataTypes.Lock()
+	defer dataTypes.Unlock()
+	if dataTypes.types == nil {
+		dataTypes.types = make(map[dataKey]reflect.Type)
+	}
+	dataTypes.types[dataKey{inputType: inputType, eventType: eventType}] = reflect.TypeOf(data)
+}
+
+// EncodeData encodes data of the event to JSON
+func EncodeData(data interface{}) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+// DecodeData decodes JSON encoded data of the event with specified type produced by the input of specified type.
+// Data is decoded to the registered type or to generic JSON value if there is no registered type.
+func DecodeData(inputType, eventType string, buf []byte) (interface{}, error) {
+	dataTypes.RLock()
+	t, ok := dataTypes.types[dataKey{inputType: inputType, eventType: eventType}]
+	dataTypes.RUnlock()
+	if !ok {
+		var data interface{}
+		err := json.Unmarshal(buf, &data)
+		return data, err
+	}
+	isPtr := t.Kind() == reflect.Ptr
+	if isPtr {
+		t = t.Elem()
+	}
+	v := reflect.New(t)
+	if err := json.Unmarshal(buf, v.Interface()); err != nil {
+		return nil, err
+	}
+	if isPtr {
+		return v.Interface(), nil
+	}
+	return v.Elem().Interface(), nil
+}
@@ -6,6 +6,10 @@ type Event struct {
 	Type  string
 	ID    string
 	Data  interface{}
+	// Key (optional) ordering key, events of the input with the same key are processed in order
+	Key string
+	// Trace (optional) W3C traceparent of the trace the processing of the event belongs to
+	Trace string
 }
 
 // Response output response structure
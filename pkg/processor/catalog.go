@@ -3,6 +3,7 @@ package processor
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/geliar/manopus/pkg/payload"
 	"github.com/geliar/manopus/pkg/report"
@@ -30,6 +31,11 @@ func Match(ctx context.Context, name string, match interface{}, payload *payload
 	return catalog.match(ctx, name, match, payload)
 }
 
+// Eval evaluates expression with specified processor
+func Eval(ctx context.Context, name string, expression interface{}, payload *payload.Payload) (value interface{}, err error) {
+	return catalog.eval(ctx, name, expression, payload)
+}
+
 func (c *catalogStore) register(ctx context.Context, processor Processor) {
 	c.Lock()
 	defer c.Unlock()
@@ -64,6 +70,8 @@ func (c *catalogStore) run(ctx context.Context, reporter report.Driver, name str
 	}
 	p := c.processors[name]
 	c.RUnlock()
+	ctx, span := startSpan(ctx, name, operationRun)
+	defer observe(span, name, operationRun, time.Now(), &err)
 	return p.Run(ctx, reporter, script, event, payload)
 }
 
@@ -80,5 +88,25 @@ func (c *catalogStore) match(ctx context.Context, name string, match interface{}
 	}
 	p := c.processors[name]
 	c.RUnlock()
+	ctx, span := startSpan(ctx, name, operationMatch)
+	defer observe(span, name, operationMatch, time.Now(), &err)
 	return p.Match(ctx, match, payload)
 }
+
+func (c *catalogStore) eval(ctx context.Context, name string, expression interface{}, payload *payload.Payload) (value interface{}, err error) {
+	c.RLock()
+
+	l := logger(ctx)
+	if _, ok := c.processors[name]; !ok {
+		c.RUnlock()
+		l.Error().
+			Str("processor_name", name).
+			Msgf("Cannot find processor with name '%s'", name)
+		return
+	}
+	p := c.processors[name]
+	c.RUnlock()
+	ctx, span := startSpan(ctx, name, operationEval)
+	defer observe(span, name, operationEval, time.Now(), &err)
+	return p.Eval(ctx, expression, payload)
+}
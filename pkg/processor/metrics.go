@@ -0,0 +1,39 @@
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/geliar/manopus/pkg/metrics"
+	"github.com/geliar/manopus/pkg/trace"
+)
+
+const (
+	operationRun   = "run"
+	operationMatch = "match"
+	operationEval  = "eval"
+)
+
+var (
+	metricDuration = metrics.NewHistogram("manopus_processor_duration_seconds",
+		"Time spent by the processor running scripts, matches and expressions.", nil, "processor", "operation")
+	metricErrors = metrics.NewCounter("manopus_processor_errors_total",
+		"Number of scripts, matches and expressions which returned error.", "processor", "operation")
+)
+
+// startSpan starts span of the processor operation
+func startSpan(ctx context.Context, name, operation string) (context.Context, *trace.Span) {
+	ctx, span := trace.Start(ctx, "processor."+operation)
+	span.SetAttr("processor", name)
+	return ctx, span
+}
+
+// observe records duration and error of the processor operation and ends its span
+func observe(span *trace.Span, name, operation string, start time.Time, err *error) {
+	metricDuration.Since(start, name, operation)
+	if *err != nil {
+		metricErrors.Inc(name, operation)
+	}
+	span.SetError(*err)
+	span.End()
+}
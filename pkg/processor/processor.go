@@ -7,18 +7,42 @@ import (
 	"github.com/geliar/manopus/pkg/report"
 )
 
-// NextStatus type of sequence statuses
-type NextStatus int
+// NextAction type of sequence transitions
+type NextAction int
 
 const (
+	// ActionContinue sequencer should continue execution with the next step
+	ActionContinue NextAction = iota
+	// ActionStop sequencer should stop execution after this step
+	ActionStop
+	// ActionRepeat sequencer should repeat this step one more time
+	ActionRepeat
+	// ActionGoto sequencer should continue execution with the named step
+	ActionGoto
+)
+
+// NextStatus type of sequence statuses
+type NextStatus struct {
+	// Action transition of the sequence
+	Action NextAction
+	// Step name of the step to continue with when Action is ActionGoto
+	Step string
+}
+
+var (
 	// NextContinue sequencer should continue execution with the next step
-	NextContinue NextStatus = iota
+	NextContinue = NextStatus{Action: ActionContinue}
 	// NextStopSequence sequencer should stop execution after this step
-	NextStopSequence
+	NextStopSequence = NextStatus{Action: ActionStop}
 	// NextRepeatStep sequencer should repeat this step one more time
-	NextRepeatStep
+	NextRepeatStep = NextStatus{Action: ActionRepeat}
 )
 
+// NextGoto returns status which asks sequencer to continue execution with the named step
+func NextGoto(step string) NextStatus {
+	return NextStatus{Action: ActionGoto, Step: step}
+}
+
 //Processor represents interface of script executor
 type Processor interface {
 	//Type get type of the Processor
@@ -27,4 +51,6 @@ type Processor interface {
 	Run(ctx context.Context, reporter report.Driver, script interface{}, event *payload.Event, payload *payload.Payload) (next NextStatus, callback interface{}, responses []payload.Response, err error)
 	//Match execution of match
 	Match(ctx context.Context, match interface{}, payload *payload.Payload) (matched bool, err error)
+	//Eval evaluation of expression
+	Eval(ctx context.Context, expression interface{}, payload *payload.Payload) (value interface{}, err error)
 }
@@ -46,17 +46,15 @@ func (p *Starlark) Type() string {
 func (p *Starlark) Run(ctx context.Context, reporter report.Driver, rawScript interface{}, event *payload.Event, payload *payload.Payload) (next processor.NextStatus, callback interface{}, responses []payload.Response, err error) {
 	next = processor.NextContinue
 	script := p.collectScript(ctx, rawScript)
-	var result *bool
+	var result *processor.NextStatus
 	result, callback, responses, err = p.run(ctx, reporter, script, event, payload)
-	if result == nil && err == nil {
-		next = processor.NextContinue
+	if err != nil {
+		next = processor.NextStopSequence
 		return
 	}
-	if result != nil && *result {
-		next = processor.NextRepeatStep
-		return
+	if result != nil {
+		next = *result
 	}
-	next = processor.NextStopSequence
 	return
 }
 
@@ -67,7 +65,14 @@ func (p Starlark) Match(ctx context.Context, rawMatch interface{}, payload *payl
 	return
 }
 
-func (p Starlark) run(ctx context.Context, reporter report.Driver, script string, event *payload.Event, pl *payload.Payload) (result *bool, respond interface{}, responses []payload.Response, err error) {
+//Eval evaluation of expression
+func (p Starlark) Eval(ctx context.Context, rawExpression interface{}, payload *payload.Payload) (value interface{}, err error) {
+	script := p.collectScript(ctx, rawExpression)
+	value, err = p.eval(ctx, script, payload)
+	return
+}
+
+func (p Starlark) run(ctx context.Context, reporter report.Driver, script string, event *payload.Event, pl *payload.Payload) (result *processor.NextStatus, respond interface{}, responses []payload.Response, err error) {
 	l := logger(ctx)
 	l.Debug().Str("script", script).
 		Msg("Executing script")
@@ -151,36 +156,55 @@ func (p Starlark) run(ctx context.Context, reporter report.Driver, script string
 		res = append(res, vexit, vstdout, vstderr)
 		return res, nil
 	})
+	globals["fail"] = starlark.NewBuiltin("fail", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var msg string
+		if err := starlark.UnpackPositionalArgs("fail", args, kwargs, 1, &msg); err != nil {
+			return starlark.None, err
+		}
+		l.Debug().
+			Str("starlark_function", "fail").
+			Str("param-msg", msg).
+			Msg("Script failed")
+		return starlark.None, errors.New(msg)
+	})
 	globals["repeat"] = func() {
 		l.Debug().
 			Str("starlark_function", "repeat").
 			Msg("Script asked to repeat the sequence")
-		r := true
+		r := processor.NextRepeatStep
 		result = &r
 	}
 	globals["stop"] = func() {
 		l.Debug().
 			Str("starlark_function", "stop").
 			Msg("Script asked to stop the sequence")
-		r := false
+		r := processor.NextStopSequence
+		result = &r
+	}
+	globals["goto"] = func(step string) {
+		l.Debug().
+			Str("starlark_function", "goto").
+			Str("param-step", step).
+			Msg("Script asked to continue with the step")
+		r := processor.NextGoto(step)
 		result = &r
 	}
 	dict, err := sconvert.MakeStringDict(globals)
 	if err != nil {
 		l.Error().Err(err).Msg("Error converting payload to Starlark globals")
-		r := false
+		r := processor.NextStopSequence
 		return &r, nil, nil, err
 	}
 	th := &starlark.Thread{}
 	_, err = starlark.ExecFile(th, "manopus_script.star", script, dict)
 	if err != nil {
 		l.Error().Err(err).Msg("Error executing Starlark script")
-		r := false
+		r := processor.NextStopSequence
 		return &r, nil, nil, err
 	}
 	pl.Export = convert.ConvertToStringMap(sconvert.FromDict(globals["export"].(*starlark.Dict))).(map[string]interface{})
 	if result != nil {
-		l.Debug().Msgf("Script execution result is %t", *result)
+		l.Debug().Msgf("Script execution result is %+v", *result)
 	}
 	return result, respond, responses, nil
 }
@@ -231,6 +255,48 @@ func (p Starlark) match(ctx context.Context, script string, pl *payload.Payload)
 	return
 }
 
+func (p Starlark) eval(ctx context.Context, script string, pl *payload.Payload) (value interface{}, err error) {
+	l := logger(ctx)
+	l.Debug().
+		Msgf("Evaluating with Starlark")
+	globals := p.makeGlobals(ctx, pl)
+	dict, err := sconvert.MakeStringDict(globals)
+	if err != nil {
+		l.Error().
+			Err(err).
+			Msg("Error converting payload to Starlark globals")
+		return nil, err
+	}
+	thread := &starlark.Thread{}
+	sf, pr, err := starlark.SourceProgram("manopus_eval.star", script, dict.Has)
+	if err != nil {
+		l.Error().Err(err).Msg("Error parsing Starlark expression")
+		return nil, err
+	}
+	var v starlark.Value = starlark.None
+	if exp := p.soleExpr(sf); exp != nil {
+		v, err = starlark.EvalExpr(thread, exp, dict)
+		if err != nil {
+			l.Debug().Err(err).Msg("Error executing Starlark expression")
+			return nil, err
+		}
+	} else {
+		// Multi-line scripts should put the value to result variable
+		res, err := pr.Init(thread, dict)
+		if err != nil {
+			l.Error().Err(err).Msg("Error executing Starlark script")
+			return nil, err
+		}
+		if r, ok := res["result"]; ok {
+			v = r
+		}
+	}
+	if v == starlark.None {
+		return nil, nil
+	}
+	return convert.ConvertToStringMap(sconvert.FromValue(v)), nil
+}
+
 func (Starlark) collectScript(ctx context.Context, script interface{}) (result string) {
 	l := logger(ctx)
 	switch v := script.(type) {
@@ -270,6 +336,7 @@ func (p Starlark) makeGlobals(ctx context.Context, payload *payload.Payload) map
 	}
 	vars, _ := convert.ToValue(payload.Vars)
 	req, _ := convert.ToValue(payload.Req)
+	resp, _ := convert.ToValue(payload.Resp)
 	export, _ := convert.ToValue(payload.Export)
 	match, _ := convert.ToValue(payload.Match)
 	event, _ := convert.ToValue(payload.Event)
@@ -278,6 +345,7 @@ func (p Starlark) makeGlobals(ctx context.Context, payload *payload.Payload) map
 		"env":    env,
 		"vars":   vars,
 		"req":    req,
+		"resp":   resp,
 		"export": export,
 		"match":  match,
 		"event":  event,
@@ -0,0 +1,9 @@
+package recorder
+
+// Config config structure of the recorder of input events
+type Config struct {
+	//File path to JSON Lines file the events are appended to. Recorder is disabled if file is empty
+	File string `yaml:"file"`
+	//Inputs (optional) the list of inputs which events should be recorded. All inputs are recorded if empty
+	Inputs []string `yaml:"inputs"`
+}
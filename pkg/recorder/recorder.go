@@ -0,0 +1,180 @@
+package recorder
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/geliar/manopus/pkg/input"
+	"github.com/geliar/manopus/pkg/payload"
+)
+
+// Record describes input event in the recording
+type Record struct {
+	//Time when the event has been received
+	Time time.Time `json:"time"`
+	//InputType type of the input the event came from
+	InputType string `json:"input_type"`
+	Input     string `json:"input"`
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	//Data JSON encoded data of the event
+	Data json.RawMessage `json:"data"`
+}
+
+// NewRecord makes record of the event received from the input of specified type
+func NewRecord(inputType string, event *payload.Event, t time.Time) (Record, error) {
+	data, err := payload.EncodeData(event.Data)
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{
+		Time:      t.UTC(),
+		InputType: inputType,
+		Input:     event.Input,
+		Type:      event.Type,
+		ID:        event.ID,
+		Data:      data,
+	}, nil
+}
+
+// Event decodes event from the record
+func (r *Record) Event() (*payload.Event, error) {
+	data, err := payload.DecodeData(r.InputType, r.Type, r.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &payload.Event{Input: r.Input, Type: r.Type, ID: r.ID, Data: data}, nil
+}
+
+// Read reads records from JSON Lines recording and calls fn for each of them
+func Read(r io.Reader, fn func(record Record) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		var record Record
+		err := dec.Decode(&record)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// Recorder writes input events to JSON Lines file
+type Recorder struct {
+	config Config
+	file   *os.File
+	sync.Mutex
+}
+
+var recorder Recorder
+
+// Init configures the recorder. It can be called again to apply the new configuration.
+func Init(ctx context.Context, config Config) {
+	recorder.Init(ctx, config)
+}
+
+// Wrap returns input handler which records the events before passing them to the handler
+func Wrap(handler input.Handler) input.Handler {
+	return recorder.Wrap(handler)
+}
+
+// Stop closes the recording file
+func Stop(ctx context.Context) {
+	recorder.Stop(ctx)
+}
+
+// Init configures the recorder
+func (r *Recorder) Init(ctx context.Context, config Config) {
+	l := logger(ctx).With().Str("recorder_file", config.File).Logger()
+	r.Lock()
+	defer r.Unlock()
+	if reflect.DeepEqual(r.config, config) {
+		return
+	}
+	r.close(ctx)
+	r.config = config
+	if config.File == "" {
+		return
+	}
+	f, err := os.OpenFile(config.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		l.Error().Err(err).Msg("Cannot open recording file, recorder is disabled")
+		r.config = Config{}
+		return
+	}
+	r.file = f
+	l.Info().Msg("Recording input events")
+}
+
+// Wrap returns input handler which records the events before passing them to the handler
+func (r *Recorder) Wrap(handler input.Handler) input.Handler {
+	return func(ctx context.Context, event *payload.Event) (callback interface{}) {
+		r.Record(ctx, event)
+		return handler(ctx, event)
+	}
+}
+
+// Record writes the event to the recording
+func (r *Recorder) Record(ctx context.Context, event *payload.Event) {
+	l := logger(ctx).With().
+		Str("event_input", event.Input).
+		Str("event_type", event.Type).
+		Str("event_id", event.ID).
+		Logger()
+	r.Lock()
+	defer r.Unlock()
+	if r.file == nil || (len(r.config.Inputs) > 0 && !contains(r.config.Inputs, event.Input)) {
+		return
+	}
+	record, err := NewRecord(input.Type(event.Input), event, time.Now())
+	if err != nil {
+		l.Error().Err(err).Msg("Cannot encode event data")
+		return
+	}
+	buf, err := json.Marshal(record)
+	if err != nil {
+		l.Error().Err(err).Msg("Cannot encode event record")
+		return
+	}
+	if _, err := r.file.Write(append(buf, '\n')); err != nil {
+		l.Error().Err(err).Msg("Cannot write event to recording file")
+	}
+}
+
+// Stop closes the recording file
+func (r *Recorder) Stop(ctx context.Context) {
+	r.Lock()
+	defer r.Unlock()
+	r.close(ctx)
+	r.config = Config{}
+}
+
+func (r *Recorder) close(ctx context.Context) {
+	l := logger(ctx)
+	if r.file == nil {
+		return
+	}
+	if err := r.file.Close(); err != nil {
+		l.Error().Err(err).Str("recorder_file", r.config.File).Msg("Cannot close recording file")
+	}
+	r.file = nil
+}
+
+func contains(s []string, str string) bool {
+	for _, v := range s {
+		if v == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package recorder
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/payload"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testData struct {
+	User    string `json:"user"`
+	Message string `json:"message"`
+}
+
+func TestRecorder(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "recorder")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+	payload.RegisterData("", "message", testData{})
+
+	r := new(Recorder)
+	r.Init(ctx, Config{File: filepath.Join(dir, "events.jsonl"), Inputs: []string{"chat"}})
+	var handled []*payload.Event
+	handler := r.Wrap(func(ctx context.Context, event *payload.Event) interface{} {
+		handled = append(handled, event)
+		return nil
+	})
+	handler(ctx, &payload.Event{Input: "chat", Type: "message", ID: "1", Data: testData{User: "alice", Message: "hi"}})
+	handler(ctx, &payload.Event{Input: "other", Type: "message", ID: "2", Data: testData{User: "bob"}})
+	handler(ctx, &payload.Event{Input: "chat", Type: "unknown", ID: "3", Data: map[string]interface{}{"n": 1}})
+	r.Stop(ctx)
+	a.Len(handled, 3)
+
+	f, err := os.Open(filepath.Join(dir, "events.jsonl"))
+	a.NoError(err)
+	defer f.Close()
+	var events []*payload.Event
+	a.NoError(Read(f, func(record Record) error {
+		a.False(record.Time.IsZero())
+		event, err := record.Event()
+		events = append(events, event)
+		return err
+	}))
+	a.Equal([]*payload.Event{
+		{Input: "chat", Type: "message", ID: "1", Data: testData{User: "alice", Message: "hi"}},
+		{Input: "chat", Type: "unknown", ID: "3", Data: map[string]interface{}{"n": float64(1)}},
+	}, events)
+}
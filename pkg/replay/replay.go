@@ -0,0 +1,90 @@
+package replay
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/geliar/manopus/pkg/config"
+	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/output"
+	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/recorder"
+	"github.com/geliar/manopus/pkg/report"
+	"github.com/geliar/manopus/pkg/sequencer"
+)
+
+// Result result of the replayed event
+type Result struct {
+	Time  time.Time `json:"time"`
+	Input string    `json:"input"`
+	Type  string    `json:"type"`
+	ID    string    `json:"id"`
+	//Callback callback data returned by the sequences
+	Callback interface{} `json:"callback"`
+	//Responses responses captured instead of sending to outputs
+	Responses []payload.Response `json:"responses"`
+	//Error error of decoding or processing the event
+	Error string `json:"error,omitempty"`
+}
+
+// Run feeds recorded events from r to the sequencer built from the configuration.
+// Connectors of the configuration are not started, responses sent to their outputs are captured
+// and passed to fn with the result of each event. State of the sequencer is not saved to the store.
+func Run(ctx context.Context, c *config.Config, r io.Reader, fn func(result Result)) error {
+	l := logger(ctx)
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	report.Init(ctx, report.Config{Driver: report.DiscardDriver})
+	for name, cn := range c.Connectors {
+		output.Register(ctx, name, captureOutput{name: name, connectorType: cn.Type})
+		defer output.Unregister(ctx, name)
+	}
+	s := &sequencer.Sequencer{
+		Env:             c.Sequencer.Env,
+		Inputs:          c.Sequencer.Inputs,
+		Processor:       c.Sequencer.Processor,
+		Reconcile:       c.Sequencer.Reconcile,
+		Fallback:        c.Sequencer.Fallback,
+		SequenceConfigs: c.Sequencer.SequenceConfigs,
+	}
+	s.Init(ctx, true)
+	defer s.Stop(ctx)
+	count := 0
+	err := recorder.Read(r, func(record recorder.Record) error {
+		count++
+		result := Result{Time: record.Time, Input: record.Input, Type: record.Type, ID: record.ID}
+		event, err := record.Event()
+		if err != nil {
+			l.Error().Err(err).Str("event_id", record.ID).Msg("Cannot decode recorded event")
+			result.Error = err.Error()
+			fn(result)
+			return nil
+		}
+		triggered, err := s.Trigger(ctx, event, "")
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Callback = triggered.Callback
+		result.Responses = triggered.Responses
+		fn(result)
+		return nil
+	})
+	l.Info().Int("replay_events", count).Msg("Replay is finished")
+	return err
+}
+
+// captureOutput output driver which captures responses instead of sending them
+type captureOutput struct {
+	name          string
+	connectorType string
+}
+
+func (o captureOutput) Name() string             { return o.name }
+func (o captureOutput) Type() string             { return o.connectorType }
+func (o captureOutput) Stop(ctx context.Context) {}
+func (o captureOutput) Send(ctx context.Context, response *payload.Response) map[string]interface{} {
+	log.Ctx(ctx).Debug().Str("output_driver_name", o.name).Msg("Captured response")
+	return nil
+}
@@ -0,0 +1,54 @@
+package replay
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/geliar/manopus/pkg/config"
+	"github.com/geliar/manopus/pkg/log"
+
+	//Connectors
+	_ "github.com/geliar/manopus/pkg/connector/bitbucket"
+	_ "github.com/geliar/manopus/pkg/connector/github"
+	_ "github.com/geliar/manopus/pkg/connector/http"
+	_ "github.com/geliar/manopus/pkg/connector/slack"
+	_ "github.com/geliar/manopus/pkg/connector/timer"
+
+	//Processors
+	_ "github.com/geliar/manopus/pkg/processor/starlark"
+
+	//Stores
+	_ "github.com/geliar/manopus/pkg/store/boltdb"
+
+	//Reporters
+	_ "github.com/geliar/manopus/pkg/report/fs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	c, err := config.Load(ctx, []string{"../../examples/test"})
+	a.NoError(err)
+	recording := strings.Join([]string{
+		`{"time":"2019-05-01T10:00:00Z","input_type":"slack","input":"slack","type":"event","id":"1","data":{"user_id":"U1","message":"hi","mentioned":true}}`,
+		`{"time":"2019-05-01T10:00:05Z","input_type":"slack","input":"slack","type":"event","id":"2","data":{"user_id":"U1","message":"my name is Bob","mentioned":true}}`,
+		`{"time":"2019-05-01T10:00:10Z","input_type":"slack","input":"slack","type":"event","id":"3","data":"broken"}`,
+	}, "\n")
+	var results []Result
+	a.NoError(Run(ctx, c, strings.NewReader(recording), func(result Result) {
+		results = append(results, result)
+	}))
+	a.Len(results, 3)
+	a.Equal("Hi <@U1>, what is your name?", results[0].Callback)
+	a.Equal("Hello Bob", results[1].Callback)
+	a.Equal("2", results[1].ID)
+	a.NotEmpty(results[2].Error)
+
+	//Outputs are unregistered after replay so it can be run again
+	a.NoError(Run(ctx, c, strings.NewReader(recording), func(result Result) {}))
+}
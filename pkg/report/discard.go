@@ -0,0 +1,30 @@
+package report
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+
+	"github.com/geliar/manopus/pkg/log"
+)
+
+// DiscardDriver name of the built-in report driver which discards reports
+const DiscardDriver = "discard"
+
+func init() {
+	Register(log.Logger.WithContext(context.Background()), DiscardDriver, func(config map[string]interface{}, id string, step int) Driver {
+		return discard{}
+	})
+}
+
+// discard report driver which discards reports
+type discard struct{}
+
+func (discard) Type() string                                  { return DiscardDriver }
+func (discard) PushString(ctx context.Context, report string) {}
+func (discard) Close(ctx context.Context)                     {}
+
+// PushReader drains the reader so the writer is not blocked
+func (discard) PushReader(ctx context.Context, report io.Reader) {
+	go func() { _, _ = io.Copy(ioutil.Discard, report) }()
+}
@@ -9,11 +9,21 @@ var config Config
 // Init initialize reporter with config
 func Init(ctx context.Context, cfg Config) {
 	l := logger(ctx)
+	catalog.Lock()
 	config = cfg
-	l.Info().Str("report_name", config.Driver).Msgf("Configured reporter %s", config.Driver)
-	if _, ok := catalog.builders[config.Driver]; !ok {
+	catalog.Unlock()
+	l.Info().Str("report_name", cfg.Driver).Msgf("Configured reporter %s", cfg.Driver)
+	if !Exists(cfg.Driver) {
 		l.Fatal().
-			Str("report_name", config.Driver).
-			Msgf("Cannot find driver with name '%s'", config.Driver)
+			Str("report_name", cfg.Driver).
+			Msgf("Cannot find driver with name '%s'", cfg.Driver)
 	}
 }
+
+// Exists checks if report driver with specified name is registered in the catalog
+func Exists(name string) bool {
+	catalog.RLock()
+	defer catalog.RUnlock()
+	_, ok := catalog.builders[name]
+	return ok
+}
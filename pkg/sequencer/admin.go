@@ -0,0 +1,115 @@
+package sequencer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/geliar/manopus/pkg/admin"
+	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/trace"
+)
+
+// AdminHandler returns handler of the admin API of the Sequencer.
+// Paths are relative to the path the handler is mounted on:
+//
+//	GET  /sequences                  list of the sequence definitions
+//	POST /sequences/{name}/enable    enable the sequence definition
+//	POST /sequences/{name}/disable   disable the sequence definition
+//	GET  /instances?sequence={name}  list of the running instances
+//	GET  /instances/{id}             running instance with its payload
+//	POST /instances/{id}/cancel      cancel the running instance
+//	POST /instances/{id}/step        move the running instance to the step {"step": "name"}
+//	GET  /instances/{id}/history     history of the running or finished instance
+//	POST /trigger                    process synthetic event
+//	                                 {"input": "name", "type": "type", "data": {}, "sequence": "name"}
+//	GET    /dead-letters             log of the events which matched no sequence, newest first
+//	DELETE /dead-letters             clear the log of the events which matched no sequence
+func (s *Sequencer) AdminHandler(ctx context.Context) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sequences", func(w http.ResponseWriter, r *http.Request) {
+		admin.WriteJSON(w, http.StatusOK, s.Definitions())
+	})
+	mux.HandleFunc("POST /sequences/{name}/enable", func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, s.SetEnabled(ctx, r.PathValue("name"), true), "enabled")
+	})
+	mux.HandleFunc("POST /sequences/{name}/disable", func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, s.SetEnabled(ctx, r.PathValue("name"), false), "disabled")
+	})
+	mux.HandleFunc("GET /instances", func(w http.ResponseWriter, r *http.Request) {
+		admin.WriteJSON(w, http.StatusOK, s.Instances(r.URL.Query().Get("sequence")))
+	})
+	mux.HandleFunc("GET /instances/{id}", func(w http.ResponseWriter, r *http.Request) {
+		instance, err := s.Instance(r.PathValue("id"))
+		if err != nil {
+			writeResult(w, err, "")
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, instance)
+	})
+	mux.HandleFunc("POST /instances/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, s.Cancel(ctx, r.PathValue("id")), "cancelled")
+	})
+	mux.HandleFunc("POST /instances/{id}/step", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Step string `json:"step"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Step == "" {
+			admin.WriteError(w, http.StatusBadRequest, "request should be JSON object with step name")
+			return
+		}
+		writeResult(w, s.Goto(ctx, r.PathValue("id"), req.Step), "moved")
+	})
+	mux.HandleFunc("GET /instances/{id}/history", func(w http.ResponseWriter, r *http.Request) {
+		history, err := s.History(ctx, r.PathValue("id"))
+		if err != nil {
+			writeResult(w, err, "")
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, history)
+	})
+	mux.HandleFunc("POST /trigger", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input    string      `json:"input"`
+			Type     string      `json:"type"`
+			ID       string      `json:"id"`
+			Data     interface{} `json:"data"`
+			Sequence string      `json:"sequence"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			admin.WriteError(w, http.StatusBadRequest, "request should be JSON object with event")
+			return
+		}
+		if req.Data == nil {
+			req.Data = map[string]interface{}{}
+		}
+		event := &payload.Event{Input: req.Input, Type: req.Type, ID: req.ID, Data: req.Data, Trace: trace.FromRequest(r)}
+		result, err := s.Trigger(ctx, event, req.Sequence)
+		if err != nil {
+			writeResult(w, err, "")
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, result)
+	})
+	mux.HandleFunc("GET /dead-letters", func(w http.ResponseWriter, r *http.Request) {
+		admin.WriteJSON(w, http.StatusOK, s.DeadLetters())
+	})
+	mux.HandleFunc("DELETE /dead-letters", func(w http.ResponseWriter, r *http.Request) {
+		s.ClearDeadLetters(ctx)
+		writeResult(w, nil, "cleared")
+	})
+	return mux
+}
+
+// writeResult writes result of the control function as JSON response
+func writeResult(w http.ResponseWriter, err error, result string) {
+	switch {
+	case errors.Is(err, errNotFound):
+		admin.WriteError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		admin.WriteError(w, http.StatusBadRequest, err.Error())
+	default:
+		admin.WriteJSON(w, http.StatusOK, map[string]interface{}{"result": result})
+	}
+}
@@ -0,0 +1,236 @@
+package sequencer
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/store"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequencer_AdminHandler(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	s := testSequencer(ctx, SequenceConfig{
+		Name: "chat",
+		Steps: []StepConfig{
+			{Name: "start", Match: "req['cmd'] == 'start'", Script: "export['n'] = req['n']"},
+			{Name: "question", Match: "req['n'] == export['n']", Script: "respond('answered')"},
+			{Name: "bye", Match: "req['n'] == export['n']", Script: "respond('bye')"},
+		},
+	})
+	h := s.AdminHandler(ctx)
+	request := func(method, path, body string, v interface{}) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if v != nil {
+			a.NoError(json.Unmarshal(w.Body.Bytes(), v))
+		}
+		return w.Code
+	}
+
+	for n := 1; n <= 2; n++ {
+		a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "start", "n": n})))
+	}
+	var instances []Instance
+	a.Equal(http.StatusOK, request("GET", "/instances?sequence=chat", "", &instances))
+	a.Len(instances, 2)
+	a.Equal("question", instances[0].StepName)
+	a.Equal(float64(2), instances[0].Export["n"])
+
+	var instance Instance
+	a.Equal(http.StatusOK, request("GET", "/instances/"+instances[1].ID, "", &instance))
+	a.Equal(instances[1].ID, instance.ID)
+	a.Equal(float64(1), instance.Payload.Export["n"])
+	a.Equal(http.StatusNotFound, request("GET", "/instances/unknown", "", nil))
+
+	//Moving the second instance to the last step and cancelling the first one
+	a.Equal(http.StatusOK, request("POST", "/instances/"+instances[0].ID+"/step", `{"step": "bye"}`, nil))
+	a.Equal(http.StatusBadRequest, request("POST", "/instances/"+instances[0].ID+"/step", `{"step": "start"}`, nil))
+	//Moved instance has no matched branch until the next event
+	a.Equal(-1, s.queue.Get(instances[0].ID).branch)
+	a.Equal("bye", s.Roll(ctx, testEvent(map[string]interface{}{"n": 2})))
+	a.Equal(http.StatusOK, request("POST", "/instances/"+instances[1].ID+"/cancel", "", nil))
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"n": 1})))
+	a.Empty(s.Instances(""))
+
+	//Disabled sequence does not start new instances
+	a.Equal(http.StatusOK, request("POST", "/sequences/chat/disable", "", nil))
+	a.Equal(http.StatusNotFound, request("POST", "/sequences/unknown/disable", "", nil))
+	var definitions []Definition
+	a.Equal(http.StatusOK, request("GET", "/sequences", "", &definitions))
+	a.Equal([]Definition{{Name: "chat", Enabled: false, Steps: []string{"start", "question", "bye"}}}, definitions)
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "start", "n": 3})))
+	a.Empty(s.Instances(""))
+	a.Equal(http.StatusOK, request("POST", "/sequences/chat/enable", "", nil))
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "start", "n": 3})))
+	a.Len(s.Instances("chat"), 1)
+}
+
+func TestSequencer_Trigger(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	s := testSequencer(ctx,
+		SequenceConfig{
+			Name:  "deploy",
+			Steps: []StepConfig{{Match: "req['cmd'] == 'deploy'", Script: "send('deployer', {'stage': req['stage']})\nrespond('deploying')"}},
+		},
+		SequenceConfig{
+			Name:  "audit",
+			Steps: []StepConfig{{Match: "req['cmd'] == 'deploy'", Script: "respond('audited')"}},
+		},
+	)
+	h := s.AdminHandler(ctx)
+	w := httptest.NewRecorder()
+	body := `{"input": "test", "type": "test", "data": {"cmd": "deploy", "stage": "prod"}, "sequence": "deploy"}`
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/trigger", strings.NewReader(body)))
+	a.Equal(http.StatusOK, w.Code)
+	var result struct {
+		Callback  interface{}
+		Responses []struct {
+			Output string
+			Data   map[string]interface{}
+		}
+	}
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &result))
+	a.Equal("deploying", result.Callback)
+	a.Len(result.Responses, 1)
+	a.Equal("deployer", result.Responses[0].Output)
+	a.Equal("prod", result.Responses[0].Data["stage"])
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/trigger", strings.NewReader(`{"input": "test", "sequence": "unknown"}`)))
+	a.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestSequencer_DeadLetters(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	s := &Sequencer{
+		Inputs:    []string{"test"},
+		Processor: "starlark",
+		Fallback:  "unknown",
+		SequenceConfigs: []SequenceConfig{
+			{Name: "greet", Steps: []StepConfig{{Types: []string{"message"}, Match: "req['cmd'] == 'hi'", Script: "respond('hello')"}}},
+			{Name: "unknown", Steps: []StepConfig{{Types: []string{"message"}, Script: "respond('?')"}}},
+		},
+	}
+	a.NoError(s.Validate())
+	s.Init(ctx, true)
+	defer s.Stop(ctx)
+	message := func(data map[string]interface{}) *payload.Event {
+		return &payload.Event{Input: "test", Type: "message", ID: "message", Data: data}
+	}
+
+	a.Equal("hello", s.Roll(ctx, message(map[string]interface{}{"cmd": "hi"})))
+	a.Nil(s.Roll(ctx, &payload.Event{Input: "other", Type: "message", ID: "other"}))
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "hi"})))
+	a.Equal("?", s.Roll(ctx, message(map[string]interface{}{"cmd": "bye", "direct": true})))
+	a.Equal("?", s.Roll(ctx, message(map[string]interface{}{"direct": true})))
+	//Only direct messages are passed to the fallback sequence
+	a.Nil(s.Roll(ctx, message(map[string]interface{}{"cmd": "bye"})))
+
+	var letters []DeadLetter
+	w := httptest.NewRecorder()
+	h := s.AdminHandler(ctx)
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/dead-letters", nil))
+	a.Equal(http.StatusOK, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &letters))
+	if a.Len(letters, 5) {
+		a.Equal(UnmatchedScript, letters[0].Reason)
+		a.Empty(letters[0].Fallback)
+		a.Equal(UnmatchedError, letters[1].Reason)
+		a.NotEmpty(letters[1].Error)
+		a.Equal("unknown", letters[1].Fallback)
+		a.Equal(UnmatchedScript, letters[2].Reason)
+		a.Equal("unknown", letters[2].Fallback)
+		a.JSONEq(`{"cmd": "bye", "direct": true}`, string(letters[2].Data))
+		a.Equal(UnmatchedType, letters[3].Reason)
+		a.Empty(letters[3].Fallback)
+		a.Equal(UnmatchedInput, letters[4].Reason)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("DELETE", "/dead-letters", nil))
+	a.Equal(http.StatusOK, w.Code)
+	a.Empty(s.DeadLetters())
+
+	//Log keeps only the latest events
+	s.DeadLetterLog.Size = 2
+	for i := 0; i < 3; i++ {
+		s.Roll(ctx, &payload.Event{Input: "other", ID: strconv.Itoa(i)})
+	}
+	letters = s.DeadLetters()
+	if a.Len(letters, 2) {
+		a.Equal("2", letters[0].ID)
+		a.Equal("1", letters[1].ID)
+	}
+
+	s.Fallback = "missing"
+	a.Error(s.Validate())
+}
+
+func TestSequencer_DeadLettersSave(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	if !store.Exists("dead_letters") {
+		store.ConfigureStore(ctx, "dead_letters", store.Config{Type: "memory"})
+	}
+	a.NoError(store.Save(ctx, "dead_letters", "test_dead_letters", nil))
+	s := &Sequencer{
+		Inputs:          []string{"test"},
+		Processor:       "starlark",
+		Store:           "dead_letters",
+		StoreKey:        "test",
+		SequenceConfigs: []SequenceConfig{{Name: "greet", Steps: []StepConfig{{Match: "False"}}}},
+	}
+	s.Init(ctx, true)
+	a.Nil(s.Roll(ctx, testEvent(nil)))
+	a.Nil(s.Roll(ctx, testEvent(nil)))
+
+	//Log is not saved on every unmatched event
+	buf, err := store.Load(ctx, "dead_letters", "test_dead_letters")
+	a.NoError(err)
+	a.Empty(buf)
+
+	//Pending entries are saved on shutdown
+	s.Stop(ctx)
+	buf, err = store.Load(ctx, "dead_letters", "test_dead_letters")
+	a.NoError(err)
+	var letters []DeadLetter
+	a.NoError(json.Unmarshal(buf, &letters))
+	a.Len(letters, 2)
+}
+
+type directMessage struct {
+	Direct bool
+}
+
+type threadMessage struct {
+	directMessage
+	Thread string
+}
+
+func TestDirect(t *testing.T) {
+	a := assert.New(t)
+	a.True(direct(&payload.Event{Data: directMessage{Direct: true}}))
+	a.True(direct(&payload.Event{Data: &threadMessage{directMessage: directMessage{Direct: true}}}))
+	a.False(direct(&payload.Event{Data: threadMessage{}}))
+	a.True(direct(&payload.Event{Data: map[string]interface{}{"direct": true}}))
+	a.False(direct(&payload.Event{Data: map[string]interface{}{"direct": "yes"}}))
+	a.False(direct(&payload.Event{Data: "direct"}))
+	a.False(direct(&payload.Event{}))
+}
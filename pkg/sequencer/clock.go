@@ -0,0 +1,34 @@
+package sequencer
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// replacedClock holds the function which replaces the system clock of the sequences
+type replacedClock struct {
+	now func() time.Time
+}
+
+var clock atomic.Value
+
+// SetClock replaces the clock of the sequences with fn. Nil fn restores the system clock.
+// Sequencers initialized with replaced clock do not start background scheduler,
+// timeouts are fired by Roll and Sweep instead.
+func SetClock(fn func() time.Time) {
+	clock.Store(replacedClock{now: fn})
+}
+
+// clockReplaced checks if the clock of the sequences has been replaced
+func clockReplaced() bool {
+	c, _ := clock.Load().(replacedClock)
+	return c.now != nil
+}
+
+// now returns current time of the sequences in UTC
+func now() time.Time {
+	if c, _ := clock.Load().(replacedClock); c.now != nil {
+		return c.now().UTC()
+	}
+	return time.Now().UTC()
+}
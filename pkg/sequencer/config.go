@@ -12,8 +12,75 @@ type SequenceConfig struct {
 	Processor string `yaml:"processor" json:"processor"`
 	//Inputs list of the inputs
 	Inputs []string `yaml:"inputs" json:"inputs"`
+	//OnTimeout (optional) handler of the step timeout for steps without own handler.
+	//It is also executed when the sequence exceeds MaxLifetime
+	OnTimeout *HandlerConfig `yaml:"on_timeout" json:"on_timeout"`
+	//MaxInstances (optional) maximum number of running instances of the sequence (unlimited by default)
+	MaxInstances int `yaml:"max_instances" json:"max_instances"`
+	//MaxLifetime (optional) time (in seconds) after the start to stop the sequence regardless of step timeouts
+	MaxLifetime int64 `yaml:"max_lifetime" json:"max_lifetime"`
+	//LimitPolicy (optional) what to do with the new instance when MaxInstances is reached:
+	//"reject" (default) ignores the event, "evict" stops the oldest instance,
+	//"queue" keeps the event in memory until one of the instances is finished
+	LimitPolicy string `yaml:"limit_policy" json:"limit_policy"`
+	//Reconcile (optional) overrides reconcile strategy of the sequencer for saved instances of this sequence
+	Reconcile string `yaml:"reconcile" json:"reconcile"`
+	//Priority (optional) sequences with higher priority are matched with the event first (0 by default)
+	Priority int `yaml:"priority" json:"priority"`
+	//Exclusive (optional) event matched by this sequence is not matched with the sequences of lower priority
+	Exclusive bool `yaml:"exclusive" json:"exclusive"`
+	//RateLimit (optional) limits the rate of the new instances of the sequence
+	RateLimit *RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
+	//Debounce (optional) collapses the events which start the sequence within the window into the last one
+	Debounce *DebounceConfig `yaml:"debounce" json:"debounce"`
 }
 
+// RateLimitConfig contains configuration of the token bucket which limits the rate of the new instances of the sequence
+type RateLimitConfig struct {
+	//Rate number of the instances which can be started per period
+	Rate int `yaml:"rate" json:"rate"`
+	//Period (optional) period (in seconds) of the rate (60 by default)
+	Period int64 `yaml:"period" json:"period"`
+	//Burst (optional) maximum number of the instances which can be started at once (Rate by default)
+	Burst int `yaml:"burst" json:"burst"`
+	//Key (optional) expression evaluated on the event which returns the key of the bucket, e.g. user or repository.
+	//All events share the same bucket by default
+	Key interface{} `yaml:"key" json:"key"`
+	//Message (optional) callback data returned for the events suppressed by the rate limit
+	Message interface{} `yaml:"message" json:"message"`
+}
+
+// DebounceConfig contains configuration of collapsing the events which start the sequence.
+// The sequence is started with the last event when the window passes without new events with the same key.
+// Held events are kept in memory only
+type DebounceConfig struct {
+	//Window period (in seconds) without new events after which the sequence is started
+	Window int64 `yaml:"window" json:"window"`
+	//Key (optional) expression evaluated on the event which returns the key of the window, e.g. user or repository.
+	//All events share the same window by default
+	Key interface{} `yaml:"key" json:"key"`
+	//Message (optional) callback data returned for the events held by debounce
+	Message interface{} `yaml:"message" json:"message"`
+}
+
+const (
+	//LimitPolicyReject ignores the event which should start new instance of the sequence
+	LimitPolicyReject = "reject"
+	//LimitPolicyEvict stops the oldest instance of the sequence to start the new one
+	LimitPolicyEvict = "evict"
+	//LimitPolicyQueue starts the new instance when one of the running instances is finished
+	LimitPolicyQueue = "queue"
+)
+
+const (
+	//ReconcileMigrate moves saved instance to the step with the same name in the new definition of the sequence
+	ReconcileMigrate = "migrate"
+	//ReconcileKeep continues saved instance with its saved definition
+	ReconcileKeep = "keep"
+	//ReconcileDrop removes saved instance which definition has been changed
+	ReconcileDrop = "drop"
+)
+
 // StepConfig contains description of the sequence step
 type StepConfig struct {
 	//Name (optional) of the step
@@ -30,6 +97,10 @@ type StepConfig struct {
 	Match interface{} `yaml:"match" json:"match"`
 	//Script contains script to execute on successful match
 	Script interface{} `yaml:"script" json:"script"`
+	//Correlation (optional) contains expression which identifies conversation of the sequence.
+	//It is evaluated on the sequence payload when the sequence starts waiting on this step
+	//and on every incoming event. Event is routed directly to the sequence with the same key.
+	Correlation interface{} `yaml:"correlation" json:"correlation"`
 	//Method contains name of the method to be executed from File
 	Method string `yaml:"method" json:"method"`
 	//Timeout (optional) time (in seconds) to cancel sequence if step is waiting longer
@@ -38,4 +109,101 @@ type StepConfig struct {
 	MaxExecutionTime int64 `yaml:"max_execution_time" json:"max_execution_time"`
 	//Processor name of processor to run the script
 	Processor string `yaml:"processor" json:"processor"`
+	//Branches (optional) list of parallel branches of the step.
+	//Every branch waits for its own events and the step is completed when Join branches are completed.
+	//Match and Script of the step are not used when branches are specified
+	Branches []BranchConfig `yaml:"branches" json:"branches"`
+	//Join (optional) number of branches to be completed to continue with the next step (all branches by default)
+	Join int `yaml:"join" json:"join"`
+	//OnTimeout (optional) handler to be executed when the step is timed out
+	OnTimeout *HandlerConfig `yaml:"on_timeout" json:"on_timeout"`
+	//Retry (optional) policy of retrying the script of the step when it fails
+	Retry *RetryConfig `yaml:"retry" json:"retry"`
+	//OnError (optional) handler to be executed when the script of the step fails after all attempts.
+	//The error is available in resp['error'] of the handler script
+	OnError *HandlerConfig `yaml:"on_error" json:"on_error"`
+	//Call (optional) calls another sequence when the sequence reaches the step.
+	//The step waits until the called sequence is finished and then runs Script with export of the called sequence in resp.
+	//Outcome of the called sequence is in resp['status']: finished, stopped, timeout or expired
+	Call *CallConfig `yaml:"call" json:"call"`
+	//Wait (optional) makes the step wait for the time instead of the event.
+	//Script of the step runs when the time comes with the wait event in req
+	Wait *WaitConfig `yaml:"wait" json:"wait"`
+}
+
+// WaitConfig contains description of the time the step waits for
+type WaitConfig struct {
+	//Duration (optional) time (in seconds) to wait after the sequence reaches the step
+	Duration int64 `yaml:"duration" json:"duration"`
+	//Until (optional) expression which returns the time to wait until: RFC3339 string or unix timestamp.
+	//It is evaluated when the sequence reaches the step
+	Until interface{} `yaml:"until" json:"until"`
+}
+
+// CallConfig contains description of the sequence call
+type CallConfig struct {
+	//Sequence name of the sequence to call
+	Sequence string `yaml:"sequence" json:"sequence"`
+	//Input (optional) expression which returns dict to be used as initial export of the called sequence
+	Input interface{} `yaml:"input" json:"input"`
+}
+
+// BranchConfig contains description of the parallel branch of the step.
+// Inputs, Types and Processor are inherited from the step if empty.
+type BranchConfig struct {
+	//Name (optional) of the branch
+	Name string `yaml:"name" json:"name"`
+	//Inputs list of inputs to match
+	Inputs []string `yaml:"inputs" json:"inputs"`
+	//Types list of event types to match
+	Types []string `yaml:"types" json:"types"`
+	//Vars list of variables to be added to payload vars field
+	Vars map[string]interface{} `yaml:"vars" json:"vars"`
+	//Match contains matcher script
+	Match interface{} `yaml:"match" json:"match"`
+	//Script contains script to execute on successful match
+	Script interface{} `yaml:"script" json:"script"`
+	//Processor name of processor to run the script
+	Processor string `yaml:"processor" json:"processor"`
+}
+
+// RetryConfig contains description of the retry policy of the step script.
+// Script can fail the attempt with fail() function.
+type RetryConfig struct {
+	//Attempts maximum number of attempts to run the script including the first one
+	Attempts int `yaml:"attempts" json:"attempts"`
+	//Backoff (optional) delay (in milliseconds) before the first retry
+	Backoff int64 `yaml:"backoff" json:"backoff"`
+	//Multiplier (optional) multiplier of the delay for every next retry (1 by default)
+	Multiplier float64 `yaml:"multiplier" json:"multiplier"`
+	//Errors (optional) list of regular expressions. Error is retried only if it matches one of them (all errors are retried by default)
+	Errors []string `yaml:"errors" json:"errors"`
+}
+
+// HandlerConfig contains description of the handler of the sequence event
+type HandlerConfig struct {
+	//Script contains script to execute
+	Script interface{} `yaml:"script" json:"script"`
+	//Step (optional) name of the step to continue with. The sequence is finished if empty.
+	//Script can override it with goto(), repeat() or stop()
+	Step string `yaml:"step" json:"step"`
+}
+
+// HistoryConfig contains configuration of the history of the sequence instances
+type HistoryConfig struct {
+	//Instances (optional) number of the latest instances which history is kept (1000 by default).
+	//Histories of older instances are removed. Negative number disables the history
+	Instances int `yaml:"instances" json:"instances"`
+	//Entries (optional) maximum number of the entries in the history of the instance (100 by default)
+	Entries int `yaml:"entries" json:"entries"`
+	//StoreKey (optional) key to use for storing the histories (store_key of the sequencer with "_history" suffix by default)
+	StoreKey string `yaml:"store_key" json:"store_key"`
+}
+
+// DeadLetterConfig contains configuration of the log of the events which matched no sequence
+type DeadLetterConfig struct {
+	//Size (optional) maximum number of the events in the log (100 by default). Negative size disables the log
+	Size int `yaml:"size" json:"size"`
+	//StoreKey (optional) key to use for storing the log (store_key of the sequencer with "_dead_letters" suffix by default)
+	StoreKey string `yaml:"store_key" json:"store_key"`
 }
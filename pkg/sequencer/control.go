@@ -0,0 +1,249 @@
+package sequencer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/geliar/manopus/pkg/payload"
+)
+
+// errNotFound is returned by control functions when sequence or instance does not exist
+var errNotFound = errors.New("not found")
+
+// Definition describes sequence definition of the Sequencer
+type Definition struct {
+	Name      string   `json:"name"`
+	Enabled   bool     `json:"enabled"`
+	Instances int      `json:"instances"`
+	Steps     []string `json:"steps"`
+}
+
+// Instance describes running instance of the sequence
+type Instance struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Step        int                    `json:"step"`
+	StepName    string                 `json:"step_name"`
+	Started     time.Time              `json:"started"`
+	LatestMatch time.Time              `json:"latest_match"`
+	Correlation string                 `json:"correlation,omitempty"`
+	Parent      string                 `json:"parent,omitempty"`
+	Child       string                 `json:"child,omitempty"`
+	WakeAt      *time.Time             `json:"wake_at,omitempty"`
+	Export      map[string]interface{} `json:"export"`
+	Payload     *payload.Payload       `json:"payload,omitempty"`
+}
+
+// Definitions returns the list of sequence definitions
+func (s *Sequencer) Definitions() (definitions []Definition) {
+	s.RLock()
+	defer s.RUnlock()
+	s.limiter.Lock()
+	defer s.limiter.Unlock()
+	for _, sc := range s.SequenceConfigs {
+		d := Definition{
+			Name:      sc.Name,
+			Enabled:   !s.disabled[sc.Name],
+			Instances: s.limiter.instances[sc.Name],
+		}
+		for _, step := range sc.Steps {
+			d.Steps = append(d.Steps, step.Name)
+		}
+		definitions = append(definitions, d)
+	}
+	return
+}
+
+// Instances returns the list of running instances of the sequences.
+// Returns only instances of the sequence with specified name if name is not empty.
+func (s *Sequencer) Instances(name string) (instances []Instance) {
+	s.RLock()
+	defer s.RUnlock()
+	for _, seq := range s.queue.Sequences() {
+		if seq.template() || (name != "" && seq.sequenceConfig.Name != name) {
+			continue
+		}
+		instances = append(instances, seq.instance(false))
+	}
+	return
+}
+
+// Instance returns running instance of the sequence with its payload
+func (s *Sequencer) Instance(id string) (Instance, error) {
+	s.RLock()
+	defer s.RUnlock()
+	seq, err := s.instance(id)
+	if err != nil {
+		return Instance{}, err
+	}
+	return seq.instance(true), nil
+}
+
+// Cancel stops running instance of the sequence with all sequences it called.
+// Cancelling of the called sequence cancels its callers.
+func (s *Sequencer) Cancel(ctx context.Context, id string) error {
+	l := logger(ctx)
+	s.Lock()
+	defer s.Unlock()
+	seq, err := s.instance(id)
+	if err != nil {
+		return err
+	}
+	for seq.parent != "" {
+		parent := s.queue.Get(seq.parent)
+		if parent == nil {
+			break
+		}
+		seq = parent
+	}
+	s.queue.Pop(seq.id)
+	s.popChildren(seq)
+	l = l.With().
+		Str("sequence_name", seq.sequenceConfig.Name).
+		Int("sequence_step", seq.step).
+		Str("sequence_id", seq.id).
+		Logger()
+	if seq.parent != "" {
+		l = l.With().Str("parent_sequence_id", seq.parent).Logger()
+	}
+	l.Info().Msg("Sequence has been cancelled")
+	s.record(ctx, seq, HistoryEntry{Kind: HistoryCancelled})
+	if seq.parent == "" {
+		_ = s.finish(mergeContexts(s.mainCtx, l.WithContext(ctx)), seq)
+	}
+	_ = s.save(ctx)
+	return nil
+}
+
+// Goto moves running instance of the sequence to the step with specified name or index.
+// Sequences called by the instance are cancelled.
+func (s *Sequencer) Goto(ctx context.Context, id string, step string) error {
+	s.Lock()
+	defer s.Unlock()
+	seq, err := s.instance(id)
+	if err != nil {
+		return err
+	}
+	index := seq.stepIndex(step)
+	if index < 0 {
+		if i, err := strconv.Atoi(step); err == nil && i >= 0 && i < len(seq.sequenceConfig.Steps) {
+			index = i
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("step '%s' %w", step, errNotFound)
+	}
+	if index == 0 {
+		return errors.New("cannot move instance to the first step, cancel it instead")
+	}
+	l := logger(ctx).With().
+		Str("sequence_name", seq.sequenceConfig.Name).
+		Int("sequence_step", seq.step).
+		Str("sequence_id", seq.id).
+		Int("sequence_next_step", index).
+		Logger()
+	if seq.parent != "" {
+		l = l.With().Str("parent_sequence_id", seq.parent).Logger()
+	}
+	s.queue.Pop(seq.id)
+	s.popChildren(seq)
+	target := seq.sequenceConfig.Steps[index].Name
+	if target == "" {
+		target = strconv.Itoa(index)
+	}
+	s.record(ctx, seq, HistoryEntry{Kind: HistoryMoved, Detail: target})
+	seq.child = ""
+	seq.step = index
+	seq.branch = -1
+	seq.completed = nil
+	seq.payload.Vars = seq.sequenceConfig.Steps[index].Vars
+	seq.latestMatch = now()
+	l.Info().Msg("Sequence has been moved to the step")
+	_ = s.enter(mergeContexts(s.mainCtx, l.WithContext(ctx)), seq)
+	s.wakeScheduler()
+	_ = s.save(ctx)
+	return nil
+}
+
+// SetEnabled enables or disables sequence definition with specified name.
+// Disabled sequence does not start new instances, running instances are not affected.
+func (s *Sequencer) SetEnabled(ctx context.Context, name string, enabled bool) error {
+	l := logger(ctx).With().Str("sequence_name", name).Logger()
+	s.Lock()
+	defer s.Unlock()
+	sc, ok := s.sequenceConfig(name)
+	if !ok || name == "" {
+		return fmt.Errorf("sequence '%s' %w", name, errNotFound)
+	}
+	if enabled {
+		delete(s.disabled, name)
+		s.pushnew(sc)
+		l.Info().Msg("Sequence has been enabled")
+	} else {
+		if s.disabled == nil {
+			s.disabled = make(map[string]bool)
+		}
+		s.disabled[name] = true
+		for _, seq := range s.queue.Sequences() {
+			if seq.template() && seq.sequenceConfig.Name == name {
+				s.queue.Pop(seq.id)
+			}
+		}
+		s.limiter.Lock()
+		delete(s.limiter.queued, name)
+		s.limiter.Unlock()
+		metricQueued.Set(0, name)
+		l.Info().Msg("Sequence has been disabled")
+	}
+	_ = s.save(ctx)
+	return nil
+}
+
+// instance returns running instance of the sequence with specified ID.
+// Warning: instance is not thread-safe Sequencer should be locked before use
+func (s *Sequencer) instance(id string) (*sequence, error) {
+	seq := s.queue.Get(id)
+	if seq == nil || seq.template() {
+		return nil, fmt.Errorf("sequence instance '%s' %w", id, errNotFound)
+	}
+	return seq, nil
+}
+
+// popChildren removes sequences called by the sequence from the queue
+func (s *Sequencer) popChildren(seq *sequence) {
+	for id := seq.child; id != ""; {
+		child := s.queue.Pop(id)
+		if child == nil {
+			return
+		}
+		id = child.child
+	}
+}
+
+// instance returns description of the sequence
+func (s *sequence) instance(withPayload bool) Instance {
+	i := Instance{
+		ID:          s.id,
+		Name:        s.sequenceConfig.Name,
+		Step:        s.step,
+		StepName:    s.sequenceConfig.Steps[s.step].Name,
+		Started:     s.started,
+		LatestMatch: s.latestMatch,
+		Correlation: s.correlation,
+		Parent:      s.parent,
+		Child:       s.child,
+		Export:      s.payload.Export,
+	}
+	if s.waiting() {
+		wakeAt := s.wakeAt
+		i.WakeAt = &wakeAt
+	}
+	if withPayload {
+		p := *s.payload
+		i.Payload = &p
+	}
+	return i
+}
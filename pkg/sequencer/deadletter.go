@@ -0,0 +1,221 @@
+package sequencer
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/geliar/manopus/pkg/metrics"
+	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/store"
+)
+
+// Reasons of the events which matched no sequence
+const (
+	//UnmatchedInput no sequence waits for the events of the input
+	UnmatchedInput = "input_filtered"
+	//UnmatchedType sequences wait for the events of the input but not of this type
+	UnmatchedType = "type_filtered"
+	//UnmatchedScript match scripts of all candidate sequences returned false
+	UnmatchedScript = "match_false"
+	//UnmatchedError match script of the candidate sequence returned error
+	UnmatchedError = "match_error"
+)
+
+// defaultDeadLetters default size of the log of the events which matched no sequence
+const defaultDeadLetters = 100
+
+// deadLettersSaveDelay delay of saving the log to store after the unmatched event,
+// so the log is saved once for the burst of unmatched events
+const deadLettersSaveDelay = 5 * time.Second
+
+var metricUnmatched = metrics.NewCounter("manopus_unmatched_events_total",
+	"Number of events which matched no sequence.", "input", "reason")
+
+// unmatched reason why event matched no sequence
+type unmatched struct {
+	reason string
+	err    error
+}
+
+// DeadLetter describes event which matched no sequence
+type DeadLetter struct {
+	Time   time.Time `json:"time"`
+	Input  string    `json:"input"`
+	Type   string    `json:"type"`
+	ID     string    `json:"id"`
+	Reason string    `json:"reason"`
+	Error  string    `json:"error,omitempty"`
+	//Fallback name of the fallback sequence which processed the event
+	Fallback string          `json:"fallback,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// deadLetters bounded log of the events which matched no sequence
+type deadLetters struct {
+	entries []DeadLetter
+	//saving timer of the pending save of the log to store
+	saving *time.Timer
+	sync.Mutex
+}
+
+// size returns maximum number of the entries of the log
+func (c DeadLetterConfig) size() int {
+	if c.Size == 0 {
+		return defaultDeadLetters
+	}
+	return c.Size
+}
+
+// deadLettersKey returns store key of the log
+func (s *Sequencer) deadLettersKey() string {
+	if s.DeadLetterLog.StoreKey != "" {
+		return s.DeadLetterLog.StoreKey
+	}
+	if s.StoreKey == "" {
+		return ""
+	}
+	return s.StoreKey + "_dead_letters"
+}
+
+// deadLetter records event which matched no sequence
+func (s *Sequencer) deadLetter(ctx context.Context, event *payload.Event, reason unmatched, fallback string) {
+	l := logger(ctx).With().Str("unmatched_reason", reason.reason).Logger()
+	if reason.err != nil {
+		l = l.With().AnErr("unmatched_error", reason.err).Logger()
+	}
+	if fallback != "" {
+		l = l.With().Str("fallback_sequence", fallback).Logger()
+	}
+	l.Debug().Msg("Event matched no sequence")
+	metricUnmatched.Inc(event.Input, reason.reason)
+	size := s.DeadLetterLog.size()
+	if size < 0 {
+		return
+	}
+	entry := DeadLetter{
+		Time:     now(),
+		Input:    event.Input,
+		Type:     event.Type,
+		ID:       event.ID,
+		Reason:   reason.reason,
+		Fallback: fallback,
+	}
+	if reason.err != nil {
+		entry.Error = reason.err.Error()
+	}
+	if buf, err := payload.EncodeData(event.Data); err == nil {
+		entry.Data = buf
+	} else {
+		l.Debug().Err(err).Msg("Cannot encode data of the unmatched event")
+	}
+	s.deadLetters.Lock()
+	s.deadLetters.entries = append(s.deadLetters.entries, entry)
+	if over := len(s.deadLetters.entries) - size; over > 0 {
+		s.deadLetters.entries = append([]DeadLetter(nil), s.deadLetters.entries[over:]...)
+	}
+	if s.deadLetters.saving == nil && s.Store != "" && s.deadLettersKey() != "" {
+		s.deadLetters.saving = time.AfterFunc(deadLettersSaveDelay, func() {
+			s.saveDeadLetters(s.mainCtx)
+		})
+	}
+	s.deadLetters.Unlock()
+}
+
+// direct checks if the event is the direct message. Inputs mark such events with the bool Direct field
+// of the data structure or the "direct" key of the data map.
+func direct(event *payload.Event) bool {
+	if data, ok := event.Data.(map[string]interface{}); ok {
+		direct, _ := data["direct"].(bool)
+		return direct
+	}
+	v := reflect.Indirect(reflect.ValueOf(event.Data))
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	field := v.FieldByName("Direct")
+	return field.IsValid() && field.Kind() == reflect.Bool && field.Bool()
+}
+
+// DeadLetters returns the log of the events which matched no sequence, newest first
+func (s *Sequencer) DeadLetters() []DeadLetter {
+	s.deadLetters.Lock()
+	defer s.deadLetters.Unlock()
+	entries := make([]DeadLetter, 0, len(s.deadLetters.entries))
+	for i := len(s.deadLetters.entries) - 1; i >= 0; i-- {
+		entries = append(entries, s.deadLetters.entries[i])
+	}
+	return entries
+}
+
+// ClearDeadLetters removes all entries from the log of the events which matched no sequence
+func (s *Sequencer) ClearDeadLetters(ctx context.Context) {
+	s.deadLetters.Lock()
+	s.deadLetters.entries = nil
+	s.deadLetters.Unlock()
+	s.saveDeadLetters(ctx)
+}
+
+func (s *Sequencer) loadDeadLetters(ctx context.Context) {
+	l := logger(ctx)
+	key := s.deadLettersKey()
+	if s.Store == "" || key == "" {
+		return
+	}
+	buf, err := store.Load(ctx, s.Store, key)
+	if err != nil {
+		l.Error().Err(err).Msg("Error on loading dead letters from store")
+		return
+	}
+	if len(buf) == 0 {
+		return
+	}
+	var entries []DeadLetter
+	if err := json.Unmarshal(buf, &entries); err != nil {
+		l.Error().Err(err).Msg("Error on parsing dead letters store value")
+		return
+	}
+	if size := s.DeadLetterLog.size(); len(entries) > size {
+		if size < 0 {
+			size = 0
+		}
+		entries = entries[len(entries)-size:]
+	}
+	s.deadLetters.Lock()
+	s.deadLetters.entries = entries
+	s.deadLetters.Unlock()
+}
+
+// flushDeadLetters saves the log to store if it has unsaved entries
+func (s *Sequencer) flushDeadLetters(ctx context.Context) {
+	s.deadLetters.Lock()
+	pending := s.deadLetters.saving != nil
+	s.deadLetters.Unlock()
+	if pending {
+		s.saveDeadLetters(ctx)
+	}
+}
+
+func (s *Sequencer) saveDeadLetters(ctx context.Context) {
+	l := logger(ctx)
+	key := s.deadLettersKey()
+	if s.Store == "" || key == "" {
+		return
+	}
+	s.deadLetters.Lock()
+	if s.deadLetters.saving != nil {
+		s.deadLetters.saving.Stop()
+		s.deadLetters.saving = nil
+	}
+	buf, err := json.Marshal(s.deadLetters.entries)
+	s.deadLetters.Unlock()
+	if err != nil {
+		l.Error().Err(err).Msg("Error on dumping dead letters to JSON")
+		return
+	}
+	if err := store.Save(ctx, s.Store, key, buf); err != nil {
+		l.Error().Err(err).Msg("Error on saving dead letters to store")
+	}
+}
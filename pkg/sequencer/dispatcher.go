@@ -0,0 +1,176 @@
+package sequencer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/geliar/manopus/pkg/input"
+	"github.com/geliar/manopus/pkg/metrics"
+	"github.com/geliar/manopus/pkg/payload"
+)
+
+// defaultQueueSize default maximum number of the events waiting for the free worker
+const defaultQueueSize = 1000
+
+var (
+	metricDispatchQueued = metrics.NewGauge("manopus_dispatch_queued_events",
+		"Number of events waiting for the free worker.")
+	metricDispatchBusy = metrics.NewGauge("manopus_dispatch_busy_workers",
+		"Number of workers processing events.")
+	metricDispatchRejected = metrics.NewCounter("manopus_dispatch_rejected_events_total",
+		"Number of events rejected because the queue of the workers is full.", "input")
+	metricDispatchWait = metrics.NewCounter("manopus_dispatch_wait_seconds_total",
+		"Total time (in seconds) events waited for the free worker.", "input")
+	metricDispatched = metrics.NewCounter("manopus_dispatch_events_total",
+		"Number of events processed by the workers.", "input")
+)
+
+// task event waiting for the worker
+type task struct {
+	ctx    context.Context
+	input  string
+	key    string
+	queued time.Time
+	run    func(ctx context.Context) interface{}
+	result chan interface{}
+}
+
+// dispatcher bounded pool of the workers which process events.
+// Events with the same ordering key are processed one by one in order of arrival,
+// other events are processed in parallel.
+type dispatcher struct {
+	limit int
+	//ready tasks which can be taken by any worker
+	ready chan *task
+	//waiting tasks which wait for the task with the same key. Presence of the key means the key is busy
+	waiting map[string][]*task
+	queued  int
+	closed  bool
+	tasks   sync.WaitGroup
+	workers sync.WaitGroup
+	quit    chan struct{}
+	sync.Mutex
+}
+
+func newDispatcher(workers, limit int) *dispatcher {
+	if limit <= 0 {
+		limit = defaultQueueSize
+	}
+	d := &dispatcher{
+		limit:   limit,
+		ready:   make(chan *task, limit),
+		waiting: make(map[string][]*task),
+		quit:    make(chan struct{}),
+	}
+	d.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go d.worker()
+	}
+	return d
+}
+
+// dispatch queues the event processing function and waits for its result.
+// Input is notified when the event is queued. Returns false if the queue is full or dispatcher is stopped.
+func (d *dispatcher) dispatch(ctx context.Context, event *payload.Event, key string, run func(ctx context.Context) interface{}) (interface{}, bool) {
+	defer input.Accept(ctx)
+	t := &task{
+		ctx:    ctx,
+		input:  event.Input,
+		queued: time.Now(),
+		run:    run,
+		result: make(chan interface{}, 1),
+	}
+	if key != "" {
+		t.key = event.Input + "/" + key
+	}
+	d.Lock()
+	if d.closed || d.queued >= d.limit {
+		d.Unlock()
+		metricDispatchRejected.Inc(event.Input)
+		return nil, false
+	}
+	d.queued++
+	d.tasks.Add(1)
+	metricDispatchQueued.Inc()
+	if waiting, busy := d.waiting[t.key]; busy && t.key != "" {
+		d.waiting[t.key] = append(waiting, t)
+		d.Unlock()
+	} else {
+		if t.key != "" {
+			d.waiting[t.key] = nil
+		}
+		d.Unlock()
+		//Number of the ready tasks never exceeds the limit so the channel never blocks
+		d.ready <- t
+	}
+	input.Accept(ctx)
+	return <-t.result, true
+}
+
+func (d *dispatcher) worker() {
+	defer d.workers.Done()
+	for {
+		select {
+		case <-d.quit:
+			return
+		case t := <-d.ready:
+			d.process(t)
+		}
+	}
+}
+
+func (d *dispatcher) process(t *task) {
+	d.Lock()
+	d.queued--
+	d.Unlock()
+	metricDispatchQueued.Dec()
+	metricDispatchWait.Add(time.Since(t.queued).Seconds(), t.input)
+	metricDispatchBusy.Inc()
+	t.result <- t.run(t.ctx)
+	metricDispatchBusy.Dec()
+	metricDispatched.Inc(t.input)
+	d.tasks.Done()
+	if t.key == "" {
+		return
+	}
+	d.Lock()
+	waiting := d.waiting[t.key]
+	if len(waiting) == 0 {
+		delete(d.waiting, t.key)
+		d.Unlock()
+		return
+	}
+	d.waiting[t.key] = waiting[1:]
+	d.Unlock()
+	d.ready <- waiting[0]
+}
+
+// stop rejects new events and waits for the queued events to be processed
+func (d *dispatcher) stop() {
+	d.Lock()
+	d.closed = true
+	d.Unlock()
+	d.tasks.Wait()
+	close(d.quit)
+	d.workers.Wait()
+}
+
+// orderingKey returns ordering key of the event: the key set by the input or
+// the correlation key of the sequences which wait for the event
+func (s *sequenceStack) orderingKey(ctx context.Context, processorName string, event *payload.Event) string {
+	if event.Key != "" {
+		return event.Key
+	}
+	var keys []string
+	for _, key := range s.correlationKeys(ctx, processorName, event) {
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	//Groups are kept in map so the smallest key is used to get the same key for the same event
+	sort.Strings(keys)
+	return keys[0]
+}
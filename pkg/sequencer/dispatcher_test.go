@@ -0,0 +1,104 @@
+package sequencer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/geliar/manopus/pkg/input"
+	"github.com/geliar/manopus/pkg/payload"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher(t *testing.T) {
+	a := assert.New(t)
+	d := newDispatcher(2, 3)
+	event := &payload.Event{Input: "test"}
+	var mu sync.Mutex
+	var order []string
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var wg sync.WaitGroup
+	//submit dispatches the task and returns when the task is queued or started if it blocks
+	submit := func(name, key string, block bool) chan bool {
+		ctx, accepted := input.WithAcceptance(context.Background())
+		dispatched := make(chan bool, 1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, ok := d.dispatch(ctx, event, key, func(ctx context.Context) interface{} {
+				if block {
+					started <- struct{}{}
+					<-release
+				}
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+				return name
+			})
+			dispatched <- ok
+		}()
+		<-accepted
+		if block {
+			<-started
+		}
+		return dispatched
+	}
+
+	submit("a1", "a", true)
+	submit("a2", "a", false)
+	submit("a3", "a", false)
+	//Event with another key is processed while the first one is blocked
+	a.True(<-submit("b1", "b", false))
+	//Queue is full: a1 and c1 are running, a2, a3 and c2 are waiting
+	submit("c1", "c", true)
+	submit("c2", "c", false)
+	a.False(<-submit("d1", "", false))
+
+	close(release)
+	wg.Wait()
+	a.Equal("b1", order[0])
+	var ordered []string
+	for _, name := range order {
+		if name[0] == 'a' {
+			ordered = append(ordered, name)
+		}
+	}
+	a.Equal([]string{"a1", "a2", "a3"}, ordered)
+
+	d.stop()
+	result, ok := d.dispatch(context.Background(), event, "", func(ctx context.Context) interface{} { return "late" })
+	a.False(ok)
+	a.Nil(result)
+}
+
+func TestSequencer_Workers(t *testing.T) {
+	ctx := context.Background()
+	a := assert.New(t)
+	s := testSequencer(ctx, SequenceConfig{
+		Name:  "echo",
+		Steps: []StepConfig{{Script: "respond(req['n'])"}},
+	})
+	s.dispatcher = newDispatcher(4, 0)
+	defer s.Stop(ctx)
+	var wg sync.WaitGroup
+	for n := 0; n < 20; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			a.Equal(int64(n), s.Roll(ctx, testEvent(map[string]interface{}{"n": n})))
+		}(n)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("events have not been processed")
+	}
+}
@@ -0,0 +1,92 @@
+package sequencer
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/geliar/manopus/pkg/processor"
+)
+
+// next returns status to move the sequence after the handler script.
+// Status returned by the script has priority over the step of the handler.
+func (h *HandlerConfig) next(status processor.NextStatus) processor.NextStatus {
+	if status.Action != processor.ActionContinue {
+		return status
+	}
+	if h.Step != "" {
+		return processor.NextGoto(h.Step)
+	}
+	return processor.NextStopSequence
+}
+
+// retryable checks if the script should be run again after the failed attempt
+func (r *RetryConfig) retryable(attempt int, err error) bool {
+	if r == nil || attempt >= r.Attempts {
+		return false
+	}
+	if len(r.Errors) == 0 {
+		return true
+	}
+	for _, e := range r.Errors {
+		if matched, _ := regexp.MatchString(e, err.Error()); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns time to wait before the next attempt after the failed one
+func (r *RetryConfig) delay(attempt int) time.Duration {
+	multiplier := r.Multiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	return time.Duration(float64(r.Backoff)*math.Pow(multiplier, float64(attempt-1))) * time.Millisecond
+}
+
+// readLockedKey marks context of the processing which holds the read lock of the Sequencer
+type readLockedKey struct{}
+
+// withReadLock returns context which tells that the processing holds the read lock of the Sequencer
+func (s *Sequencer) withReadLock(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readLockedKey{}, s)
+}
+
+// unlocked calls fn with the read lock of the Sequencer released if the processing holds it,
+// so admin actions are not blocked by long running scripts and backoffs. fn gets the channel
+// which is closed when the Sequencer is stopping. Returns false if the Sequencer has been
+// stopped meanwhile. The lock is kept if Reload is waiting, so sequences taken by the
+// processing are not missed by the reload.
+func unlocked(ctx context.Context, fn func(halt <-chan struct{})) bool {
+	s, locked := ctx.Value(readLockedKey{}).(*Sequencer)
+	if !locked {
+		fn(nil)
+		return true
+	}
+	if !s.detached.TryRLock() {
+		fn(s.halt)
+		return !s.stop
+	}
+	s.RUnlock()
+	fn(s.halt)
+	s.RLock()
+	s.detached.RUnlock()
+	return !s.stop
+}
+
+// backoff waits before the next attempt of the failed script. The read lock of the Sequencer
+// is released while waiting. Returns false if the waiting has been interrupted.
+func backoff(ctx context.Context, delay time.Duration) bool {
+	var ok bool
+	running := unlocked(ctx, func(halt <-chan struct{}) {
+		select {
+		case <-ctx.Done():
+		case <-halt:
+		case <-time.After(delay):
+			ok = true
+		}
+	})
+	return ok && running
+}
@@ -0,0 +1,351 @@
+package sequencer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/geliar/manopus/pkg/processor"
+	"github.com/geliar/manopus/pkg/store"
+)
+
+// Kinds of the entries of the history of the sequence instance
+const (
+	//HistoryStep step has been executed with the event
+	HistoryStep = "step"
+	//HistoryTimeout step has timed out
+	HistoryTimeout = "timeout"
+	//HistoryExpired instance has exceeded its lifetime
+	HistoryExpired = "expired"
+	//HistoryCalled instance has called another sequence
+	HistoryCalled = "called"
+	//HistoryCancelled instance has been cancelled with admin API
+	HistoryCancelled = "cancelled"
+	//HistoryMoved instance has been moved to another step with admin API
+	HistoryMoved = "moved"
+	//HistoryFinished instance has finished
+	HistoryFinished = "finished"
+)
+
+const (
+	//defaultHistoryInstances default number of the latest instances which history is kept
+	defaultHistoryInstances = 1000
+	//defaultHistoryEntries default maximum number of the entries in the history of the instance
+	defaultHistoryEntries = 100
+	//historySaveDelay delay of saving the changed histories to store, so the steps of the instances
+	//are saved in batches instead of the store write on every step
+	historySaveDelay = 5 * time.Second
+)
+
+// History describes how the sequence instance moved through its steps
+type History struct {
+	ID       string         `json:"id"`
+	Name     string         `json:"name"`
+	Parent   string         `json:"parent,omitempty"`
+	Started  time.Time      `json:"started"`
+	Finished *time.Time     `json:"finished,omitempty"`
+	Entries  []HistoryEntry `json:"entries"`
+}
+
+// HistoryEntry transition of the sequence instance
+type HistoryEntry struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	Step     int       `json:"step"`
+	StepName string    `json:"step_name,omitempty"`
+	//EventInput, EventType and EventID describe the event the step has been executed with
+	EventInput string `json:"event_input,omitempty"`
+	EventType  string `json:"event_type,omitempty"`
+	EventID    string `json:"event_id,omitempty"`
+	//Status outcome of the script: continue, stop, repeat or goto:<step>
+	Status string `json:"status,omitempty"`
+	//Outputs names of the outputs responses have been sent to
+	Outputs []string `json:"outputs,omitempty"`
+	//Detail ID of the called sequence or the step the instance has been moved to
+	Detail string `json:"detail,omitempty"`
+}
+
+// histories histories of the latest instances. Histories of the instances loaded from store
+// are read from store on demand
+type histories struct {
+	entries map[string]*History
+	//order IDs of the instances with history, oldest first
+	order []string
+	//changed IDs of the instances which history has not been saved to store yet
+	changed map[string]bool
+	//evicted IDs of the instances which history should be removed from store
+	evicted []string
+	//indexChanged the index has not been saved to store yet
+	indexChanged bool
+	//saving timer of the pending save of the histories to store
+	saving *time.Timer
+	sync.Mutex
+}
+
+// known checks if history of the instance is in the index. Should be called with histories locked.
+func (h *histories) known(id string) bool {
+	for _, known := range h.order {
+		if known == id {
+			return true
+		}
+	}
+	return false
+}
+
+// remove removes the instance from the index. Should be called with histories locked.
+func (h *histories) remove(id string) {
+	for i, known := range h.order {
+		if known == id {
+			h.order = append(h.order[:i:i], h.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// instances returns number of the latest instances which history is kept
+func (c HistoryConfig) instances() int {
+	if c.Instances == 0 {
+		return defaultHistoryInstances
+	}
+	return c.Instances
+}
+
+// maxEntries returns maximum number of the entries in the history of the instance
+func (c HistoryConfig) maxEntries() int {
+	if c.Entries <= 0 {
+		return defaultHistoryEntries
+	}
+	return c.Entries
+}
+
+// historyKey returns store key of the index of the histories
+func (s *Sequencer) historyKey() string {
+	if s.Store == "" {
+		return ""
+	}
+	if s.HistoryLog.StoreKey != "" {
+		return s.HistoryLog.StoreKey
+	}
+	if s.StoreKey == "" {
+		return ""
+	}
+	return s.StoreKey + "_history"
+}
+
+// nextStatus returns name of the script outcome
+func nextStatus(next processor.NextStatus) string {
+	switch next.Action {
+	case processor.ActionStop:
+		return "stop"
+	case processor.ActionRepeat:
+		return "repeat"
+	case processor.ActionGoto:
+		return "goto:" + next.Step
+	}
+	return "continue"
+}
+
+// record adds the entry to the history of the sequence instance.
+// Step of the entry is set to the current step of the instance.
+func (s *Sequencer) record(ctx context.Context, seq *sequence, entry HistoryEntry) {
+	limit := s.HistoryLog.instances()
+	if limit < 0 {
+		return
+	}
+	entry.Time = now()
+	entry.Step = seq.step
+	if seq.step < len(seq.sequenceConfig.Steps) {
+		entry.StepName = seq.sequenceConfig.Steps[seq.step].Name
+	}
+	key := s.historyKey()
+	s.histories.Lock()
+	defer s.histories.Unlock()
+	if s.histories.entries == nil {
+		s.histories.entries = make(map[string]*History)
+	}
+	h, ok := s.histories.entries[seq.id]
+	if !ok && key != "" && s.histories.known(seq.id) {
+		h = s.loadHistory(ctx, seq.id)
+		if h != nil {
+			s.histories.entries[seq.id] = h
+		}
+	}
+	if h == nil {
+		s.histories.remove(seq.id)
+		h = &History{
+			ID:      seq.id,
+			Name:    seq.sequenceConfig.Name,
+			Parent:  seq.parent,
+			Started: seq.started,
+		}
+		if h.Started.IsZero() {
+			h.Started = entry.Time
+		}
+		s.histories.entries[seq.id] = h
+		s.histories.order = append(s.histories.order, seq.id)
+		s.histories.indexChanged = true
+		s.evictHistories(ctx, limit)
+	}
+	if entry.Kind == HistoryFinished {
+		finished := entry.Time
+		h.Finished = &finished
+	}
+	//Cancelled instance is finished right after the cancellation which stays its last entry
+	cancelled := len(h.Entries) > 0 && h.Entries[len(h.Entries)-1].Kind == HistoryCancelled
+	if entry.Kind != HistoryFinished || !cancelled {
+		h.Entries = append(h.Entries, entry)
+	}
+	if over := len(h.Entries) - s.HistoryLog.maxEntries(); over > 0 {
+		h.Entries = append([]HistoryEntry(nil), h.Entries[over:]...)
+	}
+	if key == "" {
+		return
+	}
+	if s.histories.changed == nil {
+		s.histories.changed = make(map[string]bool)
+	}
+	s.histories.changed[seq.id] = true
+	if s.histories.saving == nil {
+		s.histories.saving = time.AfterFunc(historySaveDelay, func() {
+			s.flushHistories(s.mainCtx)
+		})
+	}
+}
+
+// evictHistories removes the oldest histories above the limit. Should be called with histories locked.
+func (s *Sequencer) evictHistories(ctx context.Context, limit int) {
+	over := len(s.histories.order) - limit
+	if over <= 0 {
+		return
+	}
+	for _, id := range s.histories.order[:over] {
+		delete(s.histories.entries, id)
+		delete(s.histories.changed, id)
+		s.histories.evicted = append(s.histories.evicted, id)
+	}
+	s.histories.order = append([]string(nil), s.histories.order[over:]...)
+	s.histories.indexChanged = true
+}
+
+// History returns history of the sequence instance with specified ID
+func (s *Sequencer) History(ctx context.Context, id string) (History, error) {
+	s.histories.Lock()
+	defer s.histories.Unlock()
+	h, ok := s.histories.entries[id]
+	if !ok && s.historyKey() != "" && s.histories.known(id) {
+		h = s.loadHistory(ctx, id)
+	}
+	if h == nil {
+		return History{}, fmt.Errorf("history of the instance '%s' %w", id, errNotFound)
+	}
+	history := *h
+	history.Entries = append([]HistoryEntry(nil), h.Entries...)
+	return history, nil
+}
+
+// loadHistories loads the index of the histories from store
+func (s *Sequencer) loadHistories(ctx context.Context) {
+	l := logger(ctx)
+	key := s.historyKey()
+	if key == "" || s.HistoryLog.instances() < 0 {
+		return
+	}
+	buf, err := store.Load(ctx, s.Store, key)
+	if err != nil {
+		l.Error().Err(err).Msg("Error on loading history index from store")
+		return
+	}
+	if len(buf) == 0 {
+		return
+	}
+	var order []string
+	if err := json.Unmarshal(buf, &order); err != nil {
+		l.Error().Err(err).Msg("Error on parsing history index store value")
+		return
+	}
+	s.histories.Lock()
+	s.histories.order = order
+	s.histories.indexChanged = false
+	s.evictHistories(ctx, s.HistoryLog.instances())
+	evicted := len(s.histories.evicted) > 0
+	s.histories.Unlock()
+	if evicted {
+		s.flushHistories(ctx)
+	}
+}
+
+// loadHistory loads history of the instance from store. Should be called with histories locked.
+func (s *Sequencer) loadHistory(ctx context.Context, id string) *History {
+	l := logger(ctx)
+	buf, err := store.Load(ctx, s.Store, s.historyKey()+"/"+id)
+	if err != nil {
+		l.Error().Err(err).Str("history_sequence_id", id).Msg("Error on loading history from store")
+		return nil
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+	h := new(History)
+	if err := json.Unmarshal(buf, h); err != nil {
+		l.Error().Err(err).Str("history_sequence_id", id).Msg("Error on parsing history store value")
+		return nil
+	}
+	return h
+}
+
+// flushHistories saves changed histories and the index to store and removes evicted histories from it.
+// Store is accessed without holding histories lock.
+func (s *Sequencer) flushHistories(ctx context.Context) {
+	l := logger(ctx)
+	key := s.historyKey()
+	s.histories.Lock()
+	if s.histories.saving != nil {
+		s.histories.saving.Stop()
+		s.histories.saving = nil
+	}
+	values := make(map[string][]byte, len(s.histories.changed))
+	for id := range s.histories.changed {
+		h, ok := s.histories.entries[id]
+		if !ok {
+			continue
+		}
+		buf, err := json.Marshal(h)
+		if err != nil {
+			l.Error().Err(err).Str("history_sequence_id", id).Msg("Error on dumping history to JSON")
+			continue
+		}
+		values[key+"/"+id] = buf
+	}
+	var index []byte
+	if s.histories.indexChanged {
+		var err error
+		if index, err = json.Marshal(s.histories.order); err != nil {
+			l.Error().Err(err).Msg("Error on dumping history index to JSON")
+		}
+	}
+	evicted := s.histories.evicted
+	s.histories.changed = nil
+	s.histories.evicted = nil
+	s.histories.indexChanged = false
+	s.histories.Unlock()
+	if key == "" {
+		return
+	}
+	for k, buf := range values {
+		if err := store.Save(ctx, s.Store, k, buf); err != nil {
+			l.Error().Err(err).Msg("Error on saving history to store")
+		}
+	}
+	if index != nil {
+		if err := store.Save(ctx, s.Store, key, index); err != nil {
+			l.Error().Err(err).Msg("Error on saving history index to store")
+		}
+	}
+	for _, id := range evicted {
+		if err := store.Delete(ctx, s.Store, key+"/"+id); err != nil {
+			l.Error().Err(err).Str("history_sequence_id", id).Msg("Error on removing history from store")
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package sequencer
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/store"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequencer_History(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	if !store.Exists("history") {
+		store.ConfigureStore(ctx, "history", store.Config{Type: "memory"})
+	}
+	a.NoError(store.Save(ctx, "history", "history", nil))
+	a.NoError(store.Save(ctx, "history", "history_history", nil))
+	newSequencer := func() *Sequencer {
+		s := &Sequencer{
+			Inputs:     []string{"test"},
+			Processor:  "starlark",
+			Store:      "history",
+			StoreKey:   "history",
+			HistoryLog: HistoryConfig{Instances: 2},
+			SequenceConfigs: []SequenceConfig{{
+				Name: "chat",
+				Steps: []StepConfig{
+					{Name: "start", Match: "req['cmd'] == 'start'", Script: "export['n'] = req['n']"},
+					{Name: "question", Match: "req['n'] == export['n']", Script: "respond('answered')\nstop()"},
+					{Name: "bye", Match: "False"},
+				},
+			}},
+		}
+		s.Init(ctx, false)
+		s.stopScheduler()
+		return s
+	}
+	s := newSequencer()
+	for n := 1; n <= 3; n++ {
+		a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "start", "n": n})))
+	}
+	instances := s.Instances("")
+	a.Len(instances, 3)
+	ids := make(map[int64]string)
+	for _, instance := range instances {
+		ids[instance.Export["n"].(int64)] = instance.ID
+	}
+	a.Equal("answered", s.Roll(ctx, testEvent(map[string]interface{}{"n": 3})))
+
+	//History of the oldest instance is removed because of retention limit
+	_, err := s.History(ctx, ids[1])
+	a.Error(err)
+	buf, _ := store.Load(ctx, "history", "history_history/"+ids[1])
+	a.Empty(buf)
+	//Histories are saved in batches, not on every step
+	buf, _ = store.Load(ctx, "history", "history_history/"+ids[3])
+	a.Empty(buf)
+
+	//History is loaded from store after restart
+	s.Stop(ctx)
+	s = newSequencer()
+	defer s.Stop(ctx)
+	w := httptest.NewRecorder()
+	s.AdminHandler(ctx).ServeHTTP(w, httptest.NewRequest("GET", "/instances/"+ids[3]+"/history", nil))
+	a.Equal(http.StatusOK, w.Code)
+	var history History
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &history))
+	a.Equal(ids[3], history.ID)
+	a.Equal("chat", history.Name)
+	a.NotNil(history.Finished)
+	if a.Len(history.Entries, 3) {
+		a.Equal(HistoryStep, history.Entries[0].Kind)
+		a.Equal("start", history.Entries[0].StepName)
+		a.Equal("continue", history.Entries[0].Status)
+		a.Equal("test", history.Entries[0].EventInput)
+		a.Equal("question", history.Entries[1].StepName)
+		a.Equal("stop", history.Entries[1].Status)
+		a.Equal(HistoryFinished, history.Entries[2].Kind)
+	}
+
+	history, err = s.History(ctx, ids[2])
+	a.NoError(err)
+	a.Nil(history.Finished)
+	a.Len(history.Entries, 1)
+
+	//Cancellation is the last entry of the cancelled instance
+	a.NoError(s.Cancel(ctx, ids[2]))
+	history, err = s.History(ctx, ids[2])
+	a.NoError(err)
+	a.NotNil(history.Finished)
+	if a.Len(history.Entries, 2) {
+		a.Equal(HistoryCancelled, history.Entries[1].Kind)
+	}
+	a.Equal(http.StatusNotFound, func() int {
+		w := httptest.NewRecorder()
+		s.AdminHandler(ctx).ServeHTTP(w, httptest.NewRequest("GET", "/instances/"+ids[1]+"/history", nil))
+		return w.Code
+	}())
+}
@@ -0,0 +1,166 @@
+package sequencer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/geliar/manopus/pkg/metrics"
+	"github.com/geliar/manopus/pkg/payload"
+)
+
+var (
+	metricInstances = metrics.NewGauge("manopus_sequence_instances",
+		"Number of running instances of the sequence.", "sequence")
+	metricQueued = metrics.NewGauge("manopus_sequence_queued_events",
+		"Number of events waiting for the free instance of the sequence.", "sequence")
+	metricLimitHits = metrics.NewCounter("manopus_sequence_limit_hits_total",
+		"Number of times the sequence reached max_instances limit.", "sequence", "policy")
+	metricExpired = metrics.NewCounter("manopus_sequence_expired_total",
+		"Number of instances stopped after exceeding max_lifetime.", "sequence")
+)
+
+// limiter keeps track of the running instances of the sequences
+type limiter struct {
+	instances map[string]int
+	queued    map[string][]*payload.Event
+	sync.Mutex
+}
+
+// admit checks max_instances limit of the sequence before starting the new instance.
+// Returns false if the instance should not be started.
+func (s *Sequencer) admit(ctx context.Context, seq *sequence, event *payload.Event) bool {
+	l := logger(ctx)
+	sc := &seq.sequenceConfig
+	s.limiter.Lock()
+	if sc.MaxInstances <= 0 || s.limiter.instances[sc.Name] < sc.MaxInstances {
+		s.limiter.instances[sc.Name]++
+		metricInstances.Set(float64(s.limiter.instances[sc.Name]), sc.Name)
+		s.limiter.Unlock()
+		seq.started = now()
+		return true
+	}
+	s.limiter.Unlock()
+	policy := sc.LimitPolicy
+	if policy == "" {
+		policy = LimitPolicyReject
+	}
+	metricLimitHits.Inc(sc.Name, policy)
+	l = l.With().
+		Int("sequence_max_instances", sc.MaxInstances).
+		Str("sequence_limit_policy", policy).
+		Logger()
+	switch policy {
+	case LimitPolicyEvict:
+		if s.evict(ctx, sc.Name) {
+			l.Warn().Msg("Sequence reached max instances limit. Evicted the oldest instance.")
+			seq.started = now()
+			return true
+		}
+		l.Warn().Msg("Sequence reached max instances limit and there is no instance to evict. Ignoring the event.")
+	case LimitPolicyQueue:
+		s.limiter.Lock()
+		s.limiter.queued[sc.Name] = append(s.limiter.queued[sc.Name], event)
+		queued := len(s.limiter.queued[sc.Name])
+		s.limiter.Unlock()
+		metricQueued.Set(float64(queued), sc.Name)
+		l.Warn().Int("sequence_queued_events", queued).Msg("Sequence reached max instances limit. Queued the event.")
+	default:
+		l.Warn().Msg("Sequence reached max instances limit. Ignoring the event.")
+	}
+	//Returning the template of the sequence back to the queue
+	s.pushnew(*sc)
+	return false
+}
+
+// release frees the slot of the finished instance of the sequence.
+// Returns queued event which takes the slot or nil.
+func (s *Sequencer) release(seq *sequence) *payload.Event {
+	if seq.parent != "" || seq.started.IsZero() {
+		return nil
+	}
+	name := seq.sequenceConfig.Name
+	s.limiter.Lock()
+	defer s.limiter.Unlock()
+	if queued := s.limiter.queued[name]; len(queued) > 0 {
+		s.limiter.queued[name] = queued[1:]
+		metricQueued.Set(float64(len(queued)-1), name)
+		return queued[0]
+	}
+	if s.limiter.instances[name] > 0 {
+		s.limiter.instances[name]--
+	}
+	metricInstances.Set(float64(s.limiter.instances[name]), name)
+	return nil
+}
+
+// evict stops the oldest instance of the sequence with specified name.
+// Slot of the evicted instance is passed to the new one.
+func (s *Sequencer) evict(ctx context.Context, name string) bool {
+	l := logger(ctx)
+	oldest := s.queue.Oldest(name)
+	if oldest == nil {
+		return false
+	}
+	seq := s.queue.Pop(oldest.id)
+	if seq == nil {
+		return false
+	}
+	if seq.child != "" {
+		s.queue.Pop(seq.child)
+	}
+	l.Info().
+		Str("evicted_sequence_id", seq.id).
+		Str("evicted_sequence_started", seq.started.Format(time.RFC3339)).
+		Msg("Evicted instance of the sequence")
+	return true
+}
+
+// startQueued starts the new instance of the sequence with the queued event
+func (s *Sequencer) startQueued(ctx context.Context, sc SequenceConfig, event *payload.Event) {
+	for event != nil {
+		seq := &sequence{
+			id:             s.newID(),
+			sequenceConfig: sc,
+			payload:        &payload.Payload{Env: s.Env},
+		}
+		l := logger(ctx).With().
+			Str("sequence_name", sc.Name).
+			Str("sequence_id", seq.id).
+			Str("event_id", event.ID).
+			Logger()
+		ctx := l.WithContext(ctx)
+		if !seq.Match(ctx, s.queue.inputs, s.Processor, event) {
+			l.Debug().Msg("Queued event does not match the sequence anymore")
+			seq.started = now()
+			event = s.release(seq)
+			continue
+		}
+		seq.started = now()
+		l.Debug().Msg("Starting sequence with the queued event")
+		if seq.sequenceConfig.Steps[0].Call != nil {
+			_ = s.call(ctx, seq)
+		} else {
+			_ = s.process(ctx, seq)
+		}
+		return
+	}
+}
+
+// countInstances recounts running instances of the sequences in the queue
+func (s *Sequencer) countInstances() {
+	s.limiter.Lock()
+	defer s.limiter.Unlock()
+	s.limiter.instances = make(map[string]int)
+	if s.limiter.queued == nil {
+		s.limiter.queued = make(map[string][]*payload.Event)
+	}
+	for _, seq := range s.queue.Sequences() {
+		if seq.parent == "" && !seq.started.IsZero() {
+			s.limiter.instances[seq.sequenceConfig.Name]++
+		}
+	}
+	for _, sc := range s.SequenceConfigs {
+		metricInstances.Set(float64(s.limiter.instances[sc.Name]), sc.Name)
+	}
+}
@@ -0,0 +1,72 @@
+package sequencer
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/geliar/manopus/pkg/metrics"
+	"github.com/geliar/manopus/pkg/processor"
+)
+
+var (
+	metricMatchEvaluations = metrics.NewCounter("manopus_sequence_match_evaluations_total",
+		"Number of match scripts of the sequence evaluated on events.", "sequence")
+	metricMatches = metrics.NewCounter("manopus_sequence_matches_total",
+		"Number of events matched by the sequence.", "sequence")
+	metricStepDuration = metrics.NewHistogram("manopus_sequence_step_duration_seconds",
+		"Time spent executing the step of the sequence including sending responses.", nil, "sequence", "step")
+	metricSteps = metrics.NewCounter("manopus_sequence_steps_total",
+		"Number of executed steps of the sequence by outcome (continue, stop, repeat, goto).", "sequence", "step", "outcome")
+	metricStepErrors = metrics.NewCounter("manopus_sequence_step_errors_total",
+		"Number of step scripts which returned error.", "sequence", "step")
+	metricWaiting = metrics.NewGauge("manopus_sequence_waiting_instances",
+		"Number of instances of the sequence waiting at the step.", "sequence", "step")
+)
+
+// waitingGauge remembers label values of the waiting instances gauge to reset them when instances are gone
+type waitingGauge struct {
+	seen map[[2]string]bool
+	sync.Mutex
+}
+
+// stepLabel returns name of the current step of the sequence or its index if the step has no name
+func (s *sequence) stepLabel() string {
+	if s.step < len(s.sequenceConfig.Steps) && s.sequenceConfig.Steps[s.step].Name != "" {
+		return s.sequenceConfig.Steps[s.step].Name
+	}
+	return strconv.Itoa(s.step)
+}
+
+// outcome returns outcome label of the script result
+func outcome(next processor.NextStatus) string {
+	if next.Action == processor.ActionGoto {
+		return "goto"
+	}
+	return nextStatus(next)
+}
+
+// countWaiting recounts instances waiting for events at every step of the sequences
+func (s *Sequencer) countWaiting() {
+	counts := make(map[[2]string]int)
+	for _, seq := range s.queue.Sequences() {
+		if seq.template() {
+			continue
+		}
+		counts[[2]string{seq.sequenceConfig.Name, seq.stepLabel()}]++
+	}
+	s.waiting.Lock()
+	defer s.waiting.Unlock()
+	for labels := range s.waiting.seen {
+		if _, ok := counts[labels]; !ok {
+			metricWaiting.Set(0, labels[0], labels[1])
+			delete(s.waiting.seen, labels)
+		}
+	}
+	if s.waiting.seen == nil {
+		s.waiting.seen = make(map[[2]string]bool)
+	}
+	for labels, n := range counts {
+		metricWaiting.Set(float64(n), labels[0], labels[1])
+		s.waiting.seen[labels] = true
+	}
+}
@@ -0,0 +1,45 @@
+package sequencer
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/metrics"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequencer_Metrics(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	s := testSequencer(ctx, SequenceConfig{
+		Name: "metered",
+		Steps: []StepConfig{
+			{Name: "start", Match: "req['cmd'] == 'meter'", Script: "export['n'] = req['n']"},
+			{Name: "answer", Match: "req['n'] == export['n']", Script: "stop()"},
+		},
+	})
+	defer s.Stop(ctx)
+	for n := 1; n <= 2; n++ {
+		a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "meter", "n": n})))
+	}
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"n": 1})))
+	var buf bytes.Buffer
+	a.NoError(metrics.Write(&buf))
+	out := buf.String()
+	a.Contains(out, `manopus_sequence_waiting_instances{sequence="metered",step="answer"} 1`+"\n")
+	a.Contains(out, `manopus_sequence_steps_total{sequence="metered",step="start",outcome="continue"} 2`+"\n")
+	a.Contains(out, `manopus_sequence_steps_total{sequence="metered",step="answer",outcome="stop"} 1`+"\n")
+	a.Contains(out, `manopus_sequence_matches_total{sequence="metered"} 3`+"\n")
+	a.Contains(out, `manopus_sequence_step_duration_seconds_count{sequence="metered",step="start"} 2`+"\n")
+	a.Contains(out, `manopus_processor_duration_seconds_count{processor="starlark",operation="run"}`)
+
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"n": 2})))
+	buf.Reset()
+	a.NoError(metrics.Write(&buf))
+	a.Contains(buf.String(), `manopus_sequence_waiting_instances{sequence="metered",step="answer"} 0`+"\n")
+}
@@ -0,0 +1,179 @@
+package sequencer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+)
+
+// reconcileReport counts results of the reconciliation of saved instances for every sequence
+type reconcileReport map[string]*reconcileCounters
+
+type reconcileCounters struct {
+	unchanged int
+	migrated  int
+	kept      int
+	dropped   int
+}
+
+// reconcile applies reconcile strategies to the saved sequences which definition has been changed or removed
+// and returns sequences to be restored
+func (s *Sequencer) reconcile(ctx context.Context, saved []*sequence) (sequences []*sequence) {
+	l := logger(ctx)
+	report := make(reconcileReport)
+	dropped := make(map[string]bool)
+	for _, seq := range saved {
+		if !s.reconcileSequence(ctx, seq, report.counters(seq.sequenceConfig.Name)) {
+			dropped[seq.id] = true
+		}
+	}
+	//Dropping whole chains of called sequences when one of them is dropped
+	for changed := true; changed; {
+		changed = false
+		for _, seq := range saved {
+			if dropped[seq.id] {
+				continue
+			}
+			if (seq.parent != "" && dropped[seq.parent]) || (seq.child != "" && dropped[seq.child]) {
+				l.Info().
+					Str("sequence_name", seq.sequenceConfig.Name).
+					Str("sequence_id", seq.id).
+					Msg("Dropping saved sequence linked with dropped one")
+				dropped[seq.id] = true
+				report.counters(seq.sequenceConfig.Name).dropped++
+				changed = true
+			}
+		}
+	}
+	for _, seq := range saved {
+		if !dropped[seq.id] {
+			sequences = append(sequences, seq)
+		}
+	}
+	report.log(ctx)
+	return
+}
+
+// reconcileSequence reconciles saved sequence with its current definition.
+// Returns false if the sequence should be dropped.
+func (s *Sequencer) reconcileSequence(ctx context.Context, seq *sequence, counters *reconcileCounters) bool {
+	l := logger(ctx).With().
+		Str("sequence_name", seq.sequenceConfig.Name).
+		Str("sequence_id", seq.id).
+		Int("sequence_step", seq.step).
+		Logger()
+	if seq.step < 0 || seq.step >= len(seq.sequenceConfig.Steps) {
+		l.Warn().Msg("Saved sequence has wrong step. Dropping it.")
+		counters.dropped++
+		return false
+	}
+	current, found := s.sequenceConfig(seq.sequenceConfig.Name)
+	if found && sameConfig(current, seq.sequenceConfig) {
+		counters.unchanged++
+		return true
+	}
+	strategy := s.Reconcile
+	if current.Reconcile != "" {
+		strategy = current.Reconcile
+	}
+	l = l.With().Str("sequence_reconcile", strategy).Logger()
+	switch {
+	case strategy == ReconcileKeep:
+		l.Info().Msg("Definition of the saved sequence has been changed. Keeping the saved definition.")
+		counters.kept++
+		return true
+	case strategy == ReconcileDrop:
+		l.Info().Msg("Definition of the saved sequence has been changed. Dropping it.")
+		counters.dropped++
+		return false
+	case !found:
+		l.Info().Msg("Saved sequence does not exist in configuration anymore. Dropping it.")
+		counters.dropped++
+		return false
+	}
+	name := seq.sequenceConfig.Steps[seq.step].Name
+	step := -1
+	for i := range current.Steps {
+		if name != "" && current.Steps[i].Name == name {
+			step = i
+			break
+		}
+	}
+	if step < 0 {
+		l.Info().Str("sequence_step_name", name).Msg("Cannot find step of the saved sequence in the new definition. Dropping it.")
+		counters.dropped++
+		return false
+	}
+	seq.sequenceConfig = current
+	seq.step = step
+	seq.migrateStep()
+	l.Info().
+		Str("sequence_step_name", name).
+		Int("sequence_new_step", step).
+		Msg("Migrated saved sequence to the new definition")
+	counters.migrated++
+	return true
+}
+
+// migrateStep cleans up state of the sequence which does not fit to the current step after migration
+func (s *sequence) migrateStep() {
+	step := &s.sequenceConfig.Steps[s.step]
+	if step.Correlation == nil {
+		s.correlation = ""
+	}
+	var completed []string
+	for i := range step.Branches {
+		if key := s.branchKey(i); contains(s.completed, key) {
+			completed = append(completed, key)
+		}
+	}
+	s.completed = completed
+}
+
+// sameConfig compares sequence definitions in the form they are saved to store
+func sameConfig(a, b SequenceConfig) bool {
+	bufA, errA := json.Marshal(a)
+	bufB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(bufA, bufB)
+}
+
+func (r reconcileReport) counters(name string) *reconcileCounters {
+	c, ok := r[name]
+	if !ok {
+		c = new(reconcileCounters)
+		r[name] = c
+	}
+	return c
+}
+
+// log writes report of the reconciliation to the log
+func (r reconcileReport) log(ctx context.Context) {
+	l := logger(ctx)
+	var names []string
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var total reconcileCounters
+	for _, name := range names {
+		c := r[name]
+		total.unchanged += c.unchanged
+		total.migrated += c.migrated
+		total.kept += c.kept
+		total.dropped += c.dropped
+		l.Info().
+			Str("sequence_name", name).
+			Int("unchanged", c.unchanged).
+			Int("migrated", c.migrated).
+			Int("kept", c.kept).
+			Int("dropped", c.dropped).
+			Msg("Reconciled saved instances of the sequence")
+	}
+	l.Info().
+		Int("unchanged", total.unchanged).
+		Int("migrated", total.migrated).
+		Int("kept", total.kept).
+		Int("dropped", total.dropped).
+		Msg("Reconciled saved sequences with configuration")
+}
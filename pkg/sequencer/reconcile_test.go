@@ -0,0 +1,79 @@
+package sequencer
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/payload"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequencer_Reconcile(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	saved := SequenceConfig{
+		Name: "deploy",
+		Steps: []StepConfig{
+			{Name: "start"},
+			{Name: "approve", Correlation: "req['user']"},
+			{Name: "deploy"},
+		},
+	}
+	current := SequenceConfig{
+		Name: "deploy",
+		Steps: []StepConfig{
+			{Name: "start"},
+			{Name: "check"},
+			{Name: "approve"},
+			{Name: "deploy", Vars: map[string]interface{}{"env": "prod"}},
+		},
+	}
+	instance := func(id string, sc SequenceConfig, step int) *sequence {
+		return &sequence{id: id, sequenceConfig: sc, step: step, payload: &payload.Payload{}, correlation: "alice"}
+	}
+	tests := []struct {
+		name     string
+		strategy string
+		saved    []*sequence
+		out      []string
+		steps    []int
+	}{
+		{"Unchanged", ReconcileDrop, []*sequence{instance("a", current, 1)}, []string{"a"}, []int{1}},
+		{"Migrate", "", []*sequence{instance("a", saved, 1), instance("b", saved, 2)}, []string{"a", "b"}, []int{2, 3}},
+		{"Keep", ReconcileKeep, []*sequence{instance("a", saved, 1)}, []string{"a"}, []int{1}},
+		{"Drop", ReconcileDrop, []*sequence{instance("a", saved, 1)}, nil, nil},
+		{"Unknown step", ReconcileMigrate, []*sequence{instance("a", SequenceConfig{Name: "deploy", Steps: []StepConfig{{Name: "start"}, {Name: "removed"}}}, 1)}, nil, nil},
+		{"Unknown sequence", ReconcileMigrate, []*sequence{instance("a", SequenceConfig{Name: "removed", Steps: []StepConfig{{}, {}}}, 1)}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+			s := &Sequencer{SequenceConfigs: []SequenceConfig{current}, Reconcile: tt.strategy}
+			restored := s.reconcile(ctx, tt.saved)
+			a.Equal(tt.out, sequenceIDs(restored))
+			var steps []int
+			for _, seq := range restored {
+				steps = append(steps, seq.step)
+			}
+			a.Equal(tt.steps, steps)
+		})
+	}
+}
+
+func TestSequencer_ReconcileCall(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	parent := SequenceConfig{Name: "parent", Steps: []StepConfig{{Name: "start"}, {Name: "call", Call: &CallConfig{Sequence: "child"}}}}
+	child := SequenceConfig{Name: "child", Steps: []StepConfig{{Name: "start"}, {Name: "wait"}}}
+	s := &Sequencer{SequenceConfigs: []SequenceConfig{parent}}
+	restored := s.reconcile(ctx, []*sequence{
+		{id: "child", sequenceConfig: child, step: 1, parent: "parent", payload: &payload.Payload{}},
+		{id: "parent", sequenceConfig: parent, step: 1, child: "child", payload: &payload.Payload{}},
+		{id: "other", sequenceConfig: parent, step: 1, payload: &payload.Payload{}},
+	})
+	a.Equal([]string{"other"}, sequenceIDs(restored))
+}
@@ -0,0 +1,109 @@
+package sequencer
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// scheduler fires timeouts of the waiting sequences independently of incoming events
+type scheduler struct {
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// startScheduler starts background goroutine which runs timeout handlers of the sequences on their deadlines.
+// Deadlines are computed from the sequences in queue so they survive restarts together with the saved state.
+func (s *Sequencer) startScheduler(ctx context.Context) {
+	s.scheduler = &scheduler{
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go s.schedule(ctx, s.scheduler)
+}
+
+// stopScheduler stops background goroutine and waits until it is finished
+func (s *Sequencer) stopScheduler() {
+	sc := s.scheduler
+	if sc == nil {
+		return
+	}
+	select {
+	case <-sc.done:
+	default:
+		close(sc.done)
+	}
+	<-sc.stopped
+}
+
+// wakeScheduler asks scheduler to recompute the nearest deadline
+func (s *Sequencer) wakeScheduler() {
+	sc := s.scheduler
+	if sc == nil {
+		return
+	}
+	select {
+	case sc.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Sequencer) schedule(ctx context.Context, sc *scheduler) {
+	l := logger(ctx)
+	defer close(sc.stopped)
+	for {
+		var timer *time.Timer
+		var fire <-chan time.Time
+		if deadline, ok := s.nextDeadline(ctx); ok {
+			timer = time.NewTimer(deadline.Sub(now()))
+			fire = timer.C
+			l.Debug().Time("deadline", deadline).Msg("Waiting for the next sequence timeout")
+		}
+		select {
+		case <-sc.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-sc.wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-fire:
+			s.Sweep(ctx)
+		}
+	}
+}
+
+// Sweep runs timeout handlers of the timed out sequences and starts the sequences with debounced events.
+// It is called by the scheduler on the deadlines of the sequences.
+func (s *Sequencer) Sweep(ctx context.Context) {
+	s.RLock()
+	defer s.RUnlock()
+	if s.stop {
+		return
+	}
+	atomic.AddInt64(&s.running, 1)
+	defer atomic.AddInt64(&s.running, -1)
+	ctx = s.withReadLock(ctx)
+	gclist := s.queue.GC(ctx)
+	for _, seq := range gclist {
+		s.timeout(ctx, seq)
+	}
+	started := s.startDebounced(ctx)
+	if len(gclist) == 0 && !started {
+		return
+	}
+	_ = s.save(ctx)
+}
+
+// nextDeadline returns the nearest deadline of the sequences and debounced events
+func (s *Sequencer) nextDeadline(ctx context.Context) (time.Time, bool) {
+	next, ok := s.queue.NextDeadline(ctx)
+	if debounce, has := s.nextDebounce(); has && (!ok || debounce.Before(next)) {
+		return debounce, true
+	}
+	return next, ok
+}
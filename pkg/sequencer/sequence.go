@@ -3,135 +3,654 @@ package sequencer
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/geliar/manopus/pkg/payload"
 	"github.com/geliar/manopus/pkg/processor"
 	"github.com/geliar/manopus/pkg/report"
+	"github.com/geliar/manopus/pkg/trace"
+)
+
+const (
+	//sequencerInput name of the input of the events made by Sequencer
+	sequencerInput = "sequencer"
+	//timeoutEventType type of the event which is passed to timeout handlers
+	timeoutEventType = "timeout"
+	//lifetimeEventType type of the event which is passed to timeout handler when sequence exceeds its lifetime
+	lifetimeEventType = "lifetime"
+	//waitEventType type of the event which is passed to the script of the wait step when the time comes
+	waitEventType = "wait"
+	//maxCallDepth maximum number of the nested calls of the sequences
+	maxCallDepth = 32
+)
+
+// Outcomes of the called sequence which are passed to the caller in resp['status']
+const (
+	//StatusFinished called sequence has completed all its steps
+	StatusFinished = "finished"
+	//StatusStopped called sequence has been stopped by the script or because of the error
+	StatusStopped = "stopped"
+	//StatusTimeout called sequence has been finished because of the timeout of its step
+	StatusTimeout = "timeout"
+	//StatusExpired called sequence has exceeded its lifetime
+	StatusExpired = "expired"
 )
 
 type sequence struct {
 	id             string
 	sequenceConfig SequenceConfig
 	step           int
+	branch         int
+	completed      []string
 	event          *payload.Event
 	payload        *payload.Payload
 	latestMatch    time.Time
+	//started time when the instance of the sequence has been started
+	started     time.Time
+	correlation string
+	//parent ID of the sequence which called this sequence
+	parent string
+	//child ID of the called sequence this sequence is waiting for
+	child string
+	//wakeAt time the wait step of the sequence is waiting for
+	wakeAt time.Time
+	//trace W3C traceparent of the trace the instance of the sequence has been started in
+	trace string
+	//status outcome of the finished sequence
+	status string
+}
+
+// target describes the part of the current step which is waiting for events:
+// the step itself or one of its pending branches
+type target struct {
+	branch    int
+	inputs    []string
+	types     []string
+	vars      map[string]interface{}
+	match     interface{}
+	script    interface{}
+	processor string
 }
 
 func (s *sequence) Match(ctx context.Context, inputs []string, processorName string, event *payload.Event) (matched bool) {
+	matched, _ = s.match(ctx, inputs, processorName, event)
+	return
+}
+
+// match matches event with the sequence. Returns the latest error of the match scripts.
+func (s *sequence) match(ctx context.Context, inputs []string, processorName string, event *payload.Event) (matched bool, err error) {
 	l := logger(ctx)
 	l = l.With().
 		Str("sequence_name", s.sequenceConfig.Name).
 		Int("sequence_step", s.step).Logger()
 	ctx = l.WithContext(ctx)
-	step := &s.sequenceConfig.Steps[s.step]
 
-	if len(step.Inputs) > 0 {
-		if !contains(step.Inputs, event.Input) {
-			return false
+	var span *trace.Span
+	defer func() {
+		span.SetAttr("matched", strconv.FormatBool(matched))
+		span.End()
+	}()
+	for _, t := range s.targets(inputs, processorName) {
+		if !t.accepts(event) {
+			continue
 		}
-	} else if len(s.sequenceConfig.Inputs) > 0 {
-		if !contains(s.sequenceConfig.Inputs, event.Input) {
-			return false
+		if span == nil {
+			ctx, span = s.startMatchSpan(ctx, event)
 		}
-	} else if !contains(inputs, event.Input) {
-		return false
+		newPayload := *(s.payload)
+		newPayload.Vars = t.vars
+		newPayload.Req = event.Data
+		newPayload.Event = eventInfo(event)
+		if t.match != nil {
+			var matchErr error
+			metricMatchEvaluations.Inc(s.sequenceConfig.Name)
+			matched, matchErr = processor.Match(ctx, t.processor, t.match, &newPayload)
+			if matchErr != nil {
+				err = matchErr
+			}
+			if !matched {
+				continue
+			}
+		}
+		*(s.payload) = newPayload
+		s.branch = t.branch
+		s.event = event
+		s.latestMatch = now()
+		metricMatches.Inc(s.sequenceConfig.Name)
+		return true, nil
+	}
+	return false, err
+}
+
+// snapshot returns the copy of the sequence which can be matched with events without holding
+// the lock of the stack. Maps of the payload which can be changed by scripts are copied.
+func (s *sequence) snapshot() *sequence {
+	c := *s
+	p := *(s.payload)
+	p.Match = copyMap(p.Match)
+	p.Export = copyMap(p.Export)
+	c.payload = &p
+	return &c
+}
+
+// take applies the match of the snapshot to the sequence
+func (s *sequence) take(snapshot *sequence) {
+	*(s.payload) = *(snapshot.payload)
+	s.branch = snapshot.branch
+	s.event = snapshot.event
+	s.latestMatch = snapshot.latestMatch
+}
+
+// Start prepares the first step of the called sequence to run with the event of the caller
+func (s *sequence) Start(event *payload.Event) {
+	t := s.targets(nil, "")[0]
+	s.payload.Vars = t.vars
+	s.payload.Req = event.Data
+	s.payload.Event = eventInfo(event)
+	s.branch = t.branch
+	s.event = event
+	s.latestMatch = now()
+}
+
+func (s *sequence) Run(ctx context.Context, reporter report.Driver, processorName string) (next processor.NextStatus, callback interface{}, responses []payload.Response) {
+	l := logger(ctx)
+	l = l.With().
+		Str("sequence_name", s.sequenceConfig.Name).
+		Int("sequence_step", s.step).Logger()
+	step := &s.sequenceConfig.Steps[s.step]
+	t := s.target(processorName)
+	if t.branch >= 0 {
+		l = l.With().Str("sequence_branch", s.branchKey(t.branch)).Logger()
 	}
+	ctx = l.WithContext(ctx)
 
-	if len(step.Types) > 0 {
-		if !contains(step.Types, event.Type) {
-			return false
+	if t.script != nil {
+		next, callback, responses, attempts, err := s.runRetry(ctx, reporter, t.processor, t.script)
+		if err != nil {
+			metricStepErrors.Inc(s.sequenceConfig.Name, s.stepLabel())
+			return s.onError(ctx, reporter, processorName, attempts, err)
 		}
+		return next, callback, responses
 	}
+	if step.Call != nil || step.Wait != nil {
+		return
+	}
+	l.Warn().Msg("script field is empty for the step, there is nothing to execute")
+	return
+}
 
-	newPayload := *(s.payload)
-	newPayload.Vars = step.Vars
-	newPayload.Req = event.Data
-	newPayload.Event = new(payload.EventInfo)
-	newPayload.Event.Type = event.Type
-	newPayload.Event.Input = event.Input
-	if step.Match != nil {
-		if s.sequenceConfig.Processor != "" {
-			processorName = s.sequenceConfig.Processor
+// OnTimeout runs timeout handler of the current step with the timeout event.
+// Sequence continues with the step of the handler or it is finished if the step is not specified.
+func (s *sequence) OnTimeout(ctx context.Context, reporter report.Driver, processorName string, handler *HandlerConfig) (next processor.NextStatus, callback interface{}, responses []payload.Response) {
+	l := logger(ctx)
+	l = l.With().
+		Str("sequence_name", s.sequenceConfig.Name).
+		Int("sequence_step", s.step).Logger()
+	ctx = l.WithContext(ctx)
+	s.latestMatch = now()
+	next = processor.NextContinue
+	if handler.Script != nil {
+		next, callback, responses, _ = s.run(ctx, reporter, s.processorName(processorName), handler.Script)
+	}
+	return handler.next(next), callback, responses
+}
+
+// OnExpire runs timeout handler of the sequence when the sequence exceeded its lifetime.
+// Sequence is finished regardless of the handler result.
+func (s *sequence) OnExpire(ctx context.Context, reporter report.Driver, processorName string) (next processor.NextStatus, callback interface{}, responses []payload.Response) {
+	if h := s.sequenceConfig.OnTimeout; h != nil && h.Script != nil {
+		_, callback, responses, _ = s.run(ctx, reporter, s.processorName(processorName), h.Script)
+	}
+	return processor.NextStopSequence, callback, responses
+}
+
+// timeoutHandler returns timeout handler of the current step or of the sequence
+func (s *sequence) timeoutHandler() *HandlerConfig {
+	if h := s.sequenceConfig.Steps[s.step].OnTimeout; h != nil {
+		return h
+	}
+	return s.sequenceConfig.OnTimeout
+}
+
+// timeoutEvent makes event which is passed to the timeout handler of the current step
+func (s *sequence) timeoutEvent(eventType string) *payload.Event {
+	step := &s.sequenceConfig.Steps[s.step]
+	return &payload.Event{
+		Input: sequencerInput,
+		Type:  eventType,
+		ID:    s.id,
+		Data: map[string]interface{}{
+			"sequence_name": s.sequenceConfig.Name,
+			"step":          s.step,
+			"step_name":     step.Name,
+			"timeout":       step.Timeout,
+			"max_lifetime":  s.sequenceConfig.MaxLifetime,
+		},
+	}
+}
+
+// runRetry runs the script of the step according to its retry policy
+func (s *sequence) runRetry(ctx context.Context, reporter report.Driver, processorName string, script interface{}) (next processor.NextStatus, callback interface{}, responses []payload.Response, attempts int, err error) {
+	l := logger(ctx)
+	retry := s.sequenceConfig.Steps[s.step].Retry
+	for attempts = 1; ; attempts++ {
+		next, callback, responses, err = s.run(ctx, reporter, processorName, script)
+		if err == nil || !retry.retryable(attempts, err) {
+			return
 		}
-		if step.Processor != "" {
-			processorName = step.Processor
+		delay := retry.delay(attempts)
+		l.Warn().Err(err).
+			Int("step_attempt", attempts).
+			Dur("step_retry_delay", delay).
+			Msg("Step script failed, retrying")
+		if !backoff(ctx, delay) {
+			return
 		}
+	}
+}
 
-		matched, _ = processor.Match(ctx, processorName, step.Match, &newPayload)
-		if !matched {
-			return false
+// onError runs error handler of the current step with error in resp.
+// Sequence continues with the step of the handler or it is finished if the step is not specified.
+func (s *sequence) onError(ctx context.Context, reporter report.Driver, processorName string, attempts int, err error) (next processor.NextStatus, callback interface{}, responses []payload.Response) {
+	l := logger(ctx)
+	l.Error().Err(err).Int("step_attempts", attempts).Msg("Step script failed")
+	handler := s.sequenceConfig.Steps[s.step].OnError
+	if handler == nil {
+		return processor.NextStopSequence, nil, nil
+	}
+	s.payload.Resp = map[string]interface{}{
+		"error":    err.Error(),
+		"attempts": attempts,
+	}
+	next = processor.NextContinue
+	if handler.Script != nil {
+		next, callback, responses, err = s.run(ctx, reporter, s.processorName(processorName), handler.Script)
+		if err != nil {
+			l.Error().Err(err).Msg("Error handler of the step failed")
+			return processor.NextStopSequence, nil, nil
 		}
 	}
+	return handler.next(next), callback, responses
+}
+
+func (s *sequence) run(ctx context.Context, reporter report.Driver, processorName string, script interface{}) (next processor.NextStatus, callback interface{}, responses []payload.Response, err error) {
+	step := &s.sequenceConfig.Steps[s.step]
+	runCtx := ctx
+	var cancel context.CancelFunc
+	if step.MaxExecutionTime != 0 {
+		runCtx, cancel = context.WithTimeout(runCtx, time.Duration(step.MaxExecutionTime)*time.Second)
+		defer cancel()
+	}
+	newPayload := *(s.payload)
+	if newPayload.Export == nil {
+		newPayload.Export = make(map[string]interface{})
+	}
+	next, callback, responses, err = processor.Run(runCtx, reporter, processorName, script, s.event, &newPayload)
+	s.latestMatch = now()
+	if err != nil {
+		return
+	}
 	*(s.payload) = newPayload
-	s.event = event
-	s.latestMatch = time.Now().UTC()
-	return true
+	return
 }
 
-func (s *sequence) Run(ctx context.Context, reporter report.Driver, processorName string) (next processor.NextStatus, callback interface{}, responses []payload.Response) {
+// Correlate evaluates correlation expression of the current step on the sequence payload
+// and stores the key the sequence is waiting for
+func (s *sequence) Correlate(ctx context.Context, processorName string) {
+	s.correlation = ""
+	step := &s.sequenceConfig.Steps[s.step]
+	if step.Correlation == nil || s.step == 0 {
+		return
+	}
 	l := logger(ctx)
 	l = l.With().
 		Str("sequence_name", s.sequenceConfig.Name).
 		Int("sequence_step", s.step).Logger()
 	ctx = l.WithContext(ctx)
+	value, err := processor.Eval(ctx, s.processorName(processorName), step.Correlation, s.payload)
+	if err != nil {
+		l.Error().Err(err).Msg("Cannot evaluate correlation key of the sequence")
+		return
+	}
+	s.correlation = correlationKey(value)
+	l.Debug().Str("sequence_correlation", s.correlation).Msg("Sequence is waiting for correlation key")
+}
+
+// EventCorrelation evaluates correlation expression of the current step on the event
+func (s *sequence) EventCorrelation(ctx context.Context, processorName string, event *payload.Event) string {
 	step := &s.sequenceConfig.Steps[s.step]
+	if step.Correlation == nil {
+		return ""
+	}
+	p := &payload.Payload{
+		Env:   s.payload.Env,
+		Vars:  step.Vars,
+		Req:   event.Data,
+		Event: eventInfo(event),
+	}
+	value, err := processor.Eval(ctx, s.processorName(processorName), step.Correlation, p)
+	if err != nil {
+		return ""
+	}
+	return correlationKey(value)
+}
 
-	if step.Script != nil {
-		runCtx := ctx
-		var cancel context.CancelFunc
-		if step.MaxExecutionTime != 0 {
-			runCtx, cancel = context.WithTimeout(runCtx, time.Duration(step.MaxExecutionTime)*time.Second)
-			defer cancel()
-		}
-		if s.sequenceConfig.Processor != "" {
-			processorName = s.sequenceConfig.Processor
+// processorName returns name of the processor for the current step
+func (s *sequence) processorName(defaultName string) string {
+	if p := s.sequenceConfig.Steps[s.step].Processor; p != "" {
+		return p
+	}
+	if s.sequenceConfig.Processor != "" {
+		return s.sequenceConfig.Processor
+	}
+	return defaultName
+}
+
+// inputs returns the list of inputs the current step is waiting for
+func (s *sequence) inputs(defaultInputs []string) []string {
+	step := &s.sequenceConfig.Steps[s.step]
+	if len(step.Inputs) > 0 {
+		return step.Inputs
+	}
+	if len(s.sequenceConfig.Inputs) > 0 {
+		return s.sequenceConfig.Inputs
+	}
+	return defaultInputs
+}
+
+// targets returns the list of targets of the current step which are waiting for events
+func (s *sequence) targets(defaultInputs []string, processorName string) (targets []target) {
+	step := &s.sequenceConfig.Steps[s.step]
+	if len(step.Branches) == 0 {
+		return []target{{
+			branch:    -1,
+			inputs:    s.inputs(defaultInputs),
+			types:     step.Types,
+			vars:      step.Vars,
+			match:     step.Match,
+			script:    step.Script,
+			processor: s.processorName(processorName),
+		}}
+	}
+	for i := range step.Branches {
+		if contains(s.completed, s.branchKey(i)) {
+			continue
 		}
-		if step.Processor != "" {
-			processorName = step.Processor
+		targets = append(targets, s.branchTarget(i, defaultInputs, processorName))
+	}
+	return
+}
+
+// target returns the target which has been matched with the latest event
+func (s *sequence) target(processorName string) target {
+	step := &s.sequenceConfig.Steps[s.step]
+	if len(step.Branches) == 0 || s.branch < 0 || s.branch >= len(step.Branches) {
+		return s.targets(nil, processorName)[0]
+	}
+	return s.branchTarget(s.branch, nil, processorName)
+}
+
+func (s *sequence) branchTarget(i int, defaultInputs []string, processorName string) target {
+	step := &s.sequenceConfig.Steps[s.step]
+	branch := &step.Branches[i]
+	t := target{
+		branch:    i,
+		inputs:    branch.Inputs,
+		types:     branch.Types,
+		vars:      branch.Vars,
+		match:     branch.Match,
+		script:    branch.Script,
+		processor: branch.Processor,
+	}
+	if len(t.inputs) == 0 {
+		t.inputs = s.inputs(defaultInputs)
+	}
+	if len(t.types) == 0 {
+		t.types = step.Types
+	}
+	if t.processor == "" {
+		t.processor = s.processorName(processorName)
+	}
+	return t
+}
+
+// branchKey returns identifier of the branch of the current step
+func (s *sequence) branchKey(i int) string {
+	step := &s.sequenceConfig.Steps[s.step]
+	if i >= 0 && i < len(step.Branches) && step.Branches[i].Name != "" {
+		return step.Branches[i].Name
+	}
+	return strconv.Itoa(i)
+}
+
+// accepts checks if the current step is waiting for the input and type of the event
+func (s *sequence) accepts(defaultInputs []string, event *payload.Event) bool {
+	if s.child != "" || s.waiting() {
+		return false
+	}
+	for _, t := range s.targets(defaultInputs, "") {
+		if t.accepts(event) {
+			return true
 		}
+	}
+	return false
+}
 
-		newPayload := *(s.payload)
-		if newPayload.Export == nil {
-			newPayload.Export = make(map[string]interface{})
+// correlationGroup returns identifier of the step which routes events by correlation key
+func (s *sequence) correlationGroup() correlationGroup {
+	return correlationGroup{
+		sequence:   s.sequenceConfig.Name,
+		step:       s.step,
+		expression: fmt.Sprint(s.sequenceConfig.Steps[s.step].Correlation),
+	}
+}
+
+// routes returns the list of input and event type pairs the current step is waiting for
+func (s *sequence) routes(defaultInputs []string) (routes []route) {
+	//Sequence which is waiting for the called sequence or for the time does not wait for events
+	if s.child != "" || s.waiting() {
+		return nil
+	}
+	seen := make(map[route]struct{})
+	for _, t := range s.targets(defaultInputs, "") {
+		for _, r := range t.routes() {
+			if _, ok := seen[r]; ok {
+				continue
+			}
+			seen[r] = struct{}{}
+			routes = append(routes, r)
 		}
-		next, callback, responses, _ = processor.Run(runCtx, reporter, processorName, step.Script, s.event, &newPayload)
-		*(s.payload) = newPayload
-		s.latestMatch = time.Now().UTC()
-		return
 	}
-	l.Warn().Msg("script field is empty for the step, there is nothing to execute")
 	return
 }
 
+// Next moves the sequence according to the status returned by the step script.
+// Returns true if the sequence is finished.
+func (s *sequence) Next(ctx context.Context, next processor.NextStatus) (finished bool) {
+	l := logger(ctx)
+	switch next.Action {
+	case processor.ActionRepeat:
+		return false
+	case processor.ActionStop:
+		return true
+	case processor.ActionContinue:
+		if branches := len(s.sequenceConfig.Steps[s.step].Branches); branches > 0 {
+			s.completed = append(s.completed, s.branchKey(s.branch))
+			if join := s.sequenceConfig.Steps[s.step].Join; len(s.completed) < branches && (join <= 0 || len(s.completed) < join) {
+				l.Debug().
+					Int("sequence_branches_completed", len(s.completed)).
+					Msg("Waiting for other branches of the step")
+				return false
+			}
+		}
+	}
+	s.completed = nil
+	switch next.Action {
+	case processor.ActionGoto:
+		step := s.stepIndex(next.Step)
+		if step < 0 {
+			l.Error().
+				Str("sequence_next_step_name", next.Step).
+				Msg("Cannot find step to continue with, stopping the sequence")
+			return true
+		}
+		if step == 0 {
+			l.Debug().Msg("Jump to the first step, restarting the sequence")
+			return true
+		}
+		s.step = step
+		return false
+	}
+	if s.step >= len(s.sequenceConfig.Steps)-1 {
+		return true
+	}
+	s.step++
+	return false
+}
+
+// template checks if the sequence is waiting for the event to start the new instance
+func (s *sequence) template() bool {
+	return s.parent == "" && s.started.IsZero()
+}
+
+// stepIndex returns index of the step with specified name or -1 if there is no such step
+func (s *sequence) stepIndex(name string) int {
+	for i := range s.sequenceConfig.Steps {
+		if s.sequenceConfig.Steps[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
 func (s *sequence) TimedOut(ctx context.Context) bool {
 	l := logger(ctx)
 	l = l.With().
 		Str("sequence_name", s.sequenceConfig.Name).
 		Int("sequence_step", s.step).Logger()
-	if s.sequenceConfig.Steps[s.step].Timeout > 0 && time.Now().UTC().After(s.latestMatch.Add(time.Duration(s.sequenceConfig.Steps[s.step].Timeout)*time.Second)) {
+	if deadline, ok := s.Deadline(); ok && now().After(deadline) {
 		l.Debug().Msg("Timed out")
 		return true
 	}
 	return false
 }
 
+// Deadline returns time when the current step of the sequence is timed out or the sequence exceeds its lifetime.
+// Returns false if there is no timeout.
+func (s *sequence) Deadline() (deadline time.Time, ok bool) {
+	//Sequence which has never been matched is not waiting for anything yet
+	if s.latestMatch.IsZero() {
+		return
+	}
+	if s.waiting() {
+		deadline = s.wakeAt
+		ok = true
+	} else if timeout := s.sequenceConfig.Steps[s.step].Timeout; timeout > 0 {
+		deadline = s.latestMatch.Add(time.Duration(timeout) * time.Second)
+		ok = true
+	}
+	if lifetime, has := s.lifetimeDeadline(); has && (!ok || lifetime.Before(deadline)) {
+		deadline = lifetime
+		ok = true
+	}
+	return
+}
+
+// waiting checks if the current step of the sequence is waiting for the time
+func (s *sequence) waiting() bool {
+	return s.sequenceConfig.Steps[s.step].Wait != nil && !s.wakeAt.IsZero()
+}
+
+// Wait computes the time the wait step of the sequence is waiting for
+func (s *sequence) Wait(ctx context.Context, processorName string) error {
+	wait := s.sequenceConfig.Steps[s.step].Wait
+	s.wakeAt = now().Add(time.Duration(wait.Duration) * time.Second)
+	if wait.Until == nil {
+		return nil
+	}
+	value, err := processor.Eval(ctx, s.processorName(processorName), wait.Until, s.payload)
+	if err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return err
+		}
+		s.wakeAt = t.UTC()
+	case int64:
+		s.wakeAt = time.Unix(v, 0).UTC()
+	case float64:
+		s.wakeAt = time.Unix(0, int64(v*float64(time.Second))).UTC()
+	default:
+		return fmt.Errorf("wait until should be RFC3339 string or unix timestamp, got %T", value)
+	}
+	return nil
+}
+
+// wakeEvent returns the event which is passed to the script of the wait step
+func (s *sequence) wakeEvent() *payload.Event {
+	step := &s.sequenceConfig.Steps[s.step]
+	return &payload.Event{
+		Input: sequencerInput,
+		Type:  waitEventType,
+		ID:    s.id,
+		Data: map[string]interface{}{
+			"sequence_name": s.sequenceConfig.Name,
+			"step":          s.step,
+			"step_name":     step.Name,
+			"wake_at":       s.wakeAt.Format(time.RFC3339),
+		},
+	}
+}
+
+// Expired checks if the sequence exceeded its lifetime
+func (s *sequence) Expired() bool {
+	deadline, ok := s.lifetimeDeadline()
+	return ok && now().After(deadline)
+}
+
+func (s *sequence) lifetimeDeadline() (time.Time, bool) {
+	if s.started.IsZero() || s.sequenceConfig.MaxLifetime <= 0 {
+		return time.Time{}, false
+	}
+	return s.started.Add(time.Duration(s.sequenceConfig.MaxLifetime) * time.Second), true
+}
+
 func (s *sequence) MarshalJSON() ([]byte, error) {
 	compat := struct {
 		SequenceConfig SequenceConfig
 		Step           int
+		StepName       string
 		ID             string
 		Env            map[string]interface{}
 		Export         map[string]interface{}
 		LatestMatch    int64
+		Correlation    string
+		Branches       []string
+		Parent         string
+		Child          string
+		Started        int64
+		WakeAt         int64  `json:",omitempty"`
+		Trace          string `json:",omitempty"`
 	}{
 		SequenceConfig: s.sequenceConfig,
 		Step:           s.step,
+		StepName:       s.sequenceConfig.Steps[s.step].Name,
 		ID:             s.id,
 		Env:            s.payload.Env,
 		Export:         s.payload.Export,
 		LatestMatch:    s.latestMatch.Unix(),
+		Correlation:    s.correlation,
+		Branches:       s.completed,
+		Parent:         s.parent,
+		Child:          s.child,
+		Started:        unixTime(s.started),
+		WakeAt:         unixTime(s.wakeAt),
+		Trace:          s.trace,
 	}
 	return json.Marshal(compat)
 }
@@ -140,10 +659,18 @@ func (s *sequence) UnmarshalJSON(buf []byte) (err error) {
 	compat := struct {
 		SequenceConfig SequenceConfig
 		Step           int
+		StepName       string
 		ID             string
 		Env            map[string]interface{}
 		Export         map[string]interface{}
 		LatestMatch    int64
+		Correlation    string
+		Branches       []string
+		Parent         string
+		Child          string
+		Started        int64
+		WakeAt         int64
+		Trace          string
 	}{}
 	err = json.Unmarshal(buf, &compat)
 	if err != nil {
@@ -156,9 +683,72 @@ func (s *sequence) UnmarshalJSON(buf []byte) (err error) {
 	s.payload.Export = compat.Export
 	s.latestMatch = time.Unix(compat.LatestMatch, 0)
 	s.id = compat.ID
+	s.correlation = compat.Correlation
+	s.completed = compat.Branches
+	s.parent = compat.Parent
+	s.child = compat.Child
+	s.trace = compat.Trace
+	if compat.WakeAt != 0 {
+		s.wakeAt = time.Unix(compat.WakeAt, 0)
+	}
+	if compat.Started != 0 {
+		s.started = time.Unix(compat.Started, 0)
+	} else if s.step != 0 && s.parent == "" {
+		//State saved by previous versions has no start time
+		s.started = s.latestMatch
+	}
 	return
 }
 
+// accepts checks if the target is waiting for the input and type of the event
+func (t *target) accepts(event *payload.Event) bool {
+	return contains(t.inputs, event.Input) &&
+		(len(t.types) == 0 || contains(t.types, event.Type))
+}
+
+// routes returns the list of input and event type pairs the target is waiting for
+func (t *target) routes() (routes []route) {
+	for _, input := range t.inputs {
+		if len(t.types) == 0 {
+			routes = append(routes, route{input: input})
+			continue
+		}
+		for _, eventType := range t.types {
+			routes = append(routes, route{input: input, eventType: eventType})
+		}
+	}
+	return
+}
+
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func eventInfo(event *payload.Event) *payload.EventInfo {
+	return &payload.EventInfo{
+		Type:  event.Type,
+		Input: event.Input,
+	}
+}
+
+// correlationKey converts evaluated correlation value to the string key
+func correlationKey(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(buf)
+}
+
 func contains(s []string, str string) bool {
 	for i := range s {
 		if s[i] == str {
@@ -167,3 +757,14 @@ func contains(s []string, str string) bool {
 	}
 	return false
 }
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
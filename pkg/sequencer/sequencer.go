@@ -3,11 +3,14 @@ package sequencer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/geliar/manopus/pkg/input"
 	"github.com/geliar/manopus/pkg/output"
 	"github.com/geliar/manopus/pkg/payload"
 	"github.com/geliar/manopus/pkg/processor"
@@ -27,6 +30,24 @@ type Sequencer struct {
 	Store string `yaml:"store"`
 	//StoreKey key string to use for storing sequencer state
 	StoreKey string `yaml:"store_key"`
+	//Reconcile (optional) strategy to apply to saved sequences which definition has been changed:
+	//"migrate" (default) moves them to the step with the same name in the new definition,
+	//"keep" continues them with the saved definition, "drop" removes them
+	Reconcile string `yaml:"reconcile"`
+	//Workers (optional) number of the events processed in parallel by the pool of workers.
+	//Events with the same ordering key (channel and thread of the message or correlation key) are processed in order.
+	//Events are processed by the input itself if not set
+	Workers int `yaml:"workers"`
+	//QueueSize (optional) maximum number of the events waiting for the free worker (1000 by default).
+	//Events are rejected when the queue is full
+	QueueSize int `yaml:"queue_size"`
+	//DeadLetterLog (optional) config of the log of the events which matched no sequence
+	DeadLetterLog DeadLetterConfig `yaml:"dead_letters"`
+	//HistoryLog (optional) config of the history of the sequence instances
+	HistoryLog HistoryConfig `yaml:"history"`
+	//Fallback (optional) name of the sequence which receives the direct messages which matched no other sequence.
+	//Its first step is not matched with other events
+	Fallback string `yaml:"fallback"`
 	//SequenceConfigs the list of sequence configs
 	SequenceConfigs []SequenceConfig `yaml:"sequences"`
 	queue           sequenceStack
@@ -36,22 +57,121 @@ type Sequencer struct {
 	sequenceCounter  uint64
 	sequenceIDPrefix string
 	mainCtx          context.Context
+	scheduler        *scheduler
+	//halt is closed when Sequencer is stopped to interrupt backoff of the retried steps
+	halt chan struct{}
+	//detached is read locked by the processing which released the lock of the Sequencer.
+	//Reload waits for such processing to finish
+	detached   sync.RWMutex
+	limiter    limiter
+	throttle   throttle
+	dispatcher *dispatcher
+	waiting    waitingGauge
+	//disabled names of the sequences which are disabled with admin API
+	disabled    map[string]bool
+	deadLetters deadLetters
+	histories   histories
 }
 
 // Init initializes Seqeuncer
 func (s *Sequencer) Init(ctx context.Context, noload bool) {
 	s.mainCtx = ctx
-	s.sequenceIDPrefix = time.Now().UTC().Format("20060102150405")
+	s.halt = make(chan struct{})
+	s.sequenceIDPrefix = now().Format("20060102150405")
+	s.queue.inputs = s.Inputs
+	s.queue.fallback = s.Fallback
 	if s.Store != "" && s.StoreKey != "" && !noload {
 		_ = s.load(ctx)
+		s.loadDeadLetters(ctx)
+		s.loadHistories(ctx)
 	}
 	for _, sc := range s.SequenceConfigs {
 		s.pushnew(sc)
 	}
+	s.countInstances()
+	if s.Workers > 0 {
+		s.dispatcher = newDispatcher(s.Workers, s.QueueSize)
+	}
+	if !clockReplaced() {
+		s.startScheduler(ctx)
+	}
 }
 
-// Roll process event with sequences
+// Reload applies configuration of the sequences, env, inputs and processor from next to the running Sequencer.
+// Templates of the sequences are replaced with the new definitions and running instances are reconciled with them.
+// Store and worker settings are not reloaded. Reload waits for the processing which released the lock
+// of the Sequencer, e.g. for the backoff of the retried step, so taken sequences are reconciled too.
+func (s *Sequencer) Reload(ctx context.Context, next *Sequencer) error {
+	l := logger(ctx)
+	if err := next.Validate(); err != nil {
+		return err
+	}
+	//Sequences taken by the processing which released the lock are not in the queue
+	s.detached.Lock()
+	defer s.detached.Unlock()
+	s.Lock()
+	defer s.Unlock()
+	if s.stop {
+		return errors.New("sequencer is stopped")
+	}
+	if next.Store != s.Store || next.StoreKey != s.StoreKey {
+		l.Warn().Msg("Changing store of the sequencer requires restart")
+	}
+	s.Env = next.Env
+	s.Inputs = next.Inputs
+	s.Processor = next.Processor
+	s.Reconcile = next.Reconcile
+	s.DeadLetterLog = next.DeadLetterLog
+	s.Fallback = next.Fallback
+	s.SequenceConfigs = next.SequenceConfigs
+	var instances []*sequence
+	for _, seq := range s.queue.Reset() {
+		if seq.template() {
+			continue
+		}
+		seq.payload.Env = s.Env
+		instances = append(instances, seq)
+	}
+	s.queue.inputs = s.Inputs
+	s.queue.fallback = s.Fallback
+	instances = s.reconcile(ctx, instances)
+	for i := len(instances) - 1; i >= 0; i-- {
+		s.queue.Push(instances[i])
+	}
+	for _, sc := range s.SequenceConfigs {
+		s.pushnew(sc)
+	}
+	s.countInstances()
+	s.wakeScheduler()
+	_ = s.save(ctx)
+	l.Info().Int("sequences", len(s.SequenceConfigs)).Msg("Sequencer configuration has been reloaded")
+	return nil
+}
+
+// Roll process event with sequences. Event is passed to the pool of workers if it is configured.
 func (s *Sequencer) Roll(ctx context.Context, event *payload.Event) (response interface{}) {
+	if s.dispatcher == nil {
+		//Without workers events are processed in parallel by the inputs without ordering
+		input.Accept(ctx)
+		return s.roll(ctx, event, "")
+	}
+	key := s.queue.orderingKey(ctx, s.Processor, event)
+	response, ok := s.dispatcher.dispatch(ctx, event, key, func(ctx context.Context) interface{} {
+		return s.roll(ctx, event, "")
+	})
+	if !ok {
+		l := logger(ctx)
+		l.Warn().
+			Str("event_input", event.Input).
+			Str("event_type", event.Type).
+			Str("event_id", event.ID).
+			Msg("Event has been rejected, the queue of the workers is full or sequencer is stopped")
+	}
+	return
+}
+
+// roll process event with sequences which have specified name or with all sequences if name is empty
+func (s *Sequencer) roll(ctx context.Context, event *payload.Event, name string) (response interface{}) {
 	l := logger(ctx).With().
 		Str("event_input", event.Input).
 		Str("event_type", event.Type).
@@ -66,94 +186,410 @@ func (s *Sequencer) Roll(ctx context.Context, event *payload.Event) (response in
 	}
 	atomic.AddInt64(&s.running, 1)
 	defer atomic.AddInt64(&s.running, -1)
+	ctx = s.withReadLock(ctx)
 	gclist := s.queue.GC(ctx)
 	for _, seq := range gclist {
-		s.pushnew(seq.sequenceConfig)
-		l.Debug().Str("sequence_name", seq.sequenceConfig.Name).Msg("Cleaning timed out sequence")
+		s.timeout(ctx, seq)
 	}
 	ctx = mergeContexts(s.mainCtx, ctx)
-	sequences := s.queue.Match(ctx, s.Inputs, s.Processor, event)
+	sequences, reason := s.queue.MatchSequence(ctx, s.Processor, event, name)
+	if len(sequences) == 0 && name == "" {
+		fallback := ""
+		if s.Fallback != "" && direct(event) {
+			sequences, _ = s.queue.MatchSequence(ctx, s.Processor, event, s.Fallback)
+			if len(sequences) > 0 {
+				fallback = s.Fallback
+			}
+		}
+		s.deadLetter(ctx, event, reason, fallback)
+	}
 	for _, seq := range sequences {
 		if s.stop {
 			return
 		}
-		l = l.With().
+		l := l.With().
 			Str("sequence_name", seq.sequenceConfig.Name).
 			Int("sequence_step", seq.step).
 			Str("sequence_id", seq.id).
 			Logger()
-		ctx = l.WithContext(ctx)
+		if seq.parent != "" {
+			l = l.With().Str("parent_sequence_id", seq.parent).Logger()
+		}
+		ctx := l.WithContext(ctx)
 		l.Debug().
 			Msg("Event matched")
-		if !seq.sequenceConfig.Single && seq.step == 0 {
+		if !seq.sequenceConfig.Single && seq.step == 0 && seq.parent == "" {
 			l.Debug().Msg("sequence can be executed in parallel. Creating new one.")
 			s.pushnew(seq.sequenceConfig)
 		}
-		var next processor.NextStatus
-		reporter := report.Open(ctx, seq.id, seq.step)
-		// Running specified processor
-		next, callback, responses := seq.Run(ctx, reporter, s.Processor)
-
-		reporter.Close(ctx)
+		if seq.template() {
+			if suppressed, message := s.suppress(ctx, seq, event); suppressed {
+				if message != nil {
+					response = message
+				}
+				continue
+			}
+			if !s.admit(ctx, seq, event) {
+				continue
+			}
+		}
+		var callback interface{}
+		if seq.sequenceConfig.Steps[seq.step].Call != nil {
+			callback = s.call(ctx, seq)
+		} else {
+			callback = s.process(ctx, seq)
+		}
 		if callback != nil {
 			if response != nil {
 				l.Warn().Msg("Multiple sequences returned callback data. Using the latest one.")
 			}
 			response = callback
 		}
-		//Sending requests to outputs
-		for _, r := range responses {
-			if s.stop {
-				return
-			}
-			r.ID = event.ID
-			r.Request = event
+	}
+	s.wakeScheduler()
+	_ = s.save(ctx)
+	return
+}
 
-			if r.Output != "" {
-				s.sendToOutput(ctx, &r)
-			}
-		}
+// process runs the current step of the sequence, sends responses to outputs and moves sequence to the next step
+func (s *Sequencer) process(ctx context.Context, seq *sequence) (response interface{}) {
+	return s.execute(ctx, seq, func(ctx context.Context, reporter report.Driver) (processor.NextStatus, interface{}, []payload.Response) {
+		return seq.Run(ctx, reporter, s.Processor)
+	})
+}
+
+// timeout runs timeout handler of the timed out sequence
+func (s *Sequencer) timeout(ctx context.Context, seq *sequence) {
+	l := logger(ctx).With().
+		Str("sequence_name", seq.sequenceConfig.Name).
+		Int("sequence_step", seq.step).
+		Str("sequence_id", seq.id).
+		Logger()
+	if seq.parent != "" {
+		l = l.With().Str("parent_sequence_id", seq.parent).Logger()
+	}
+	ctx = l.WithContext(ctx)
+	if seq.child != "" {
+		s.queue.Pop(seq.child)
+	}
+	expired := seq.Expired()
+	if seq.waiting() && !expired {
+		s.wake(ctx, seq)
+		return
+	}
+	event := seq.timeoutEvent(timeoutEventType)
+	if expired {
+		event = seq.timeoutEvent(lifetimeEventType)
+	}
+	step := &seq.sequenceConfig.Steps[seq.step]
+	seq.event = event
+	seq.correlation = ""
+	seq.completed = nil
+	seq.payload.Vars = step.Vars
+	seq.payload.Req = event.Data
+	seq.payload.Event = eventInfo(event)
+	if expired {
+		seq.status = StatusExpired
+		l.Warn().Int64("sequence_max_lifetime", seq.sequenceConfig.MaxLifetime).Msg("Sequence exceeded its lifetime, stopping it")
+		metricExpired.Inc(seq.sequenceConfig.Name)
+		s.record(ctx, seq, HistoryEntry{Kind: HistoryExpired})
+		_ = s.execute(ctx, seq, func(ctx context.Context, reporter report.Driver) (processor.NextStatus, interface{}, []payload.Response) {
+			return seq.OnExpire(ctx, reporter, s.Processor)
+		})
+		return
+	}
+	s.record(ctx, seq, HistoryEntry{Kind: HistoryTimeout})
+	seq.status = StatusTimeout
+	handler := seq.timeoutHandler()
+	if handler == nil {
+		l.Debug().Msg("Cleaning timed out sequence")
+		s.advance(ctx, seq, processor.NextStopSequence)
+		return
+	}
+	l.Info().Msg("Sequence is timed out, running timeout handler")
+	callback := s.execute(ctx, seq, func(ctx context.Context, reporter report.Driver) (processor.NextStatus, interface{}, []payload.Response) {
+		return seq.OnTimeout(ctx, reporter, s.Processor, handler)
+	})
+	if callback != nil {
+		l.Debug().Msg("Timeout handler returned callback data. Ignoring it.")
+	}
+}
+
+// wake runs the script of the wait step of the sequence when the time comes
+func (s *Sequencer) wake(ctx context.Context, seq *sequence) {
+	l := logger(ctx)
+	event := seq.wakeEvent()
+	step := &seq.sequenceConfig.Steps[seq.step]
+	seq.wakeAt = time.Time{}
+	seq.event = event
+	seq.branch = -1
+	seq.latestMatch = now()
+	seq.payload.Vars = step.Vars
+	seq.payload.Req = event.Data
+	seq.payload.Event = eventInfo(event)
+	l.Debug().Msg("Wait is over, running the step")
+	_ = s.execute(ctx, seq, func(ctx context.Context, reporter report.Driver) (processor.NextStatus, interface{}, []payload.Response) {
+		return seq.Run(ctx, reporter, s.Processor)
+	})
+}
 
+// execute runs the sequence with run function, sends responses to outputs and moves sequence to the next step
+func (s *Sequencer) execute(ctx context.Context, seq *sequence, run func(ctx context.Context, reporter report.Driver) (processor.NextStatus, interface{}, []payload.Response)) (response interface{}) {
+	name, step := seq.sequenceConfig.Name, seq.stepLabel()
+	defer metricStepDuration.Since(time.Now(), name, step)
+	ctx, span := seq.startSpan(ctx, "sequence.run")
+	defer span.End()
+	reporter := report.Open(ctx, seq.id, seq.step)
+	// Running specified processor
+	next, callback, responses := run(ctx, reporter)
+	reporter.Close(ctx)
+	metricSteps.Inc(name, step, outcome(next))
+	span.SetAttr("sequence_status", nextStatus(next))
+	response = callback
+	entry := HistoryEntry{Kind: HistoryStep, Status: nextStatus(next)}
+	if seq.event != nil {
+		entry.EventInput = seq.event.Input
+		entry.EventType = seq.event.Type
+		entry.EventID = seq.event.ID
+	}
+	for _, r := range responses {
+		if r.Output != "" {
+			entry.Outputs = append(entry.Outputs, r.Output)
+		}
+	}
+	s.record(ctx, seq, entry)
+	//Sending requests to outputs
+	for _, r := range responses {
 		if s.stop {
 			return
 		}
-		//If this step is not last
-		if next == processor.NextRepeatStep ||
-			(seq.step < len(seq.sequenceConfig.Steps)-1 &&
-				next != processor.NextStopSequence) {
-			//Pushing sequence back to queue but with incremented step number
-			if next != processor.NextRepeatStep {
-				seq.step++
-			}
-			//Cleanup
-			seq.payload.Req = nil
-			seq.payload.Event = nil
-			seq.payload.Resp = nil
-			//Pushing sequence back to queue
-			s.queue.Push(seq)
-			l.Debug().
-				Msg("Next step")
-		} else {
-			//If it is the last step starting sequence from beginning
-			s.pushnew(seq.sequenceConfig)
-			l.Debug().Msg("sequence is finished. Creating new one.")
+		r.ID = seq.event.ID
+		r.Request = seq.event
+
+		if r.Output != "" {
+			s.sendToOutput(ctx, &r)
 		}
+		collect(ctx, r)
+	}
+	if s.stop {
+		return
+	}
+	if callback := s.advance(ctx, seq, next); callback != nil {
+		response = callback
 	}
-	_ = s.save(ctx)
 	return
 }
 
+// advance moves sequence to the next step and pushes it back to the queue.
+// Starts the called sequence if the next step has call.
+func (s *Sequencer) advance(ctx context.Context, seq *sequence, next processor.NextStatus) (response interface{}) {
+	if seq.Next(ctx, next) {
+		if seq.status == "" && next.Action != processor.ActionStop {
+			seq.status = StatusFinished
+		}
+		return s.finish(ctx, seq)
+	}
+	return s.enter(ctx, seq)
+}
+
+// enter prepares sequence to wait for events of its current step and pushes it back to the queue.
+// Starts the called sequence if the step has call.
+func (s *Sequencer) enter(ctx context.Context, seq *sequence) (response interface{}) {
+	l := logger(ctx)
+	//Sequence goes on, e.g. with the step of the timeout handler
+	seq.status = ""
+	if seq.sequenceConfig.Steps[seq.step].Call != nil {
+		return s.call(ctx, seq)
+	}
+	seq.wakeAt = time.Time{}
+	if seq.sequenceConfig.Steps[seq.step].Wait != nil {
+		if err := seq.Wait(ctx, s.Processor); err != nil {
+			l.Error().Err(err).Msg("Cannot evaluate time of the wait step, stopping the sequence")
+			return s.finish(ctx, seq)
+		}
+		l.Debug().Time("sequence_wake_at", seq.wakeAt).Msg("Sequence is waiting for the time")
+	}
+	seq.Correlate(ctx, s.Processor)
+	//Cleanup
+	seq.payload.Req = nil
+	seq.payload.Event = nil
+	seq.payload.Resp = nil
+	//Pushing sequence back to queue
+	s.queue.Push(seq)
+	l.Debug().
+		Int("sequence_next_step", seq.step).
+		Msg("Next step")
+	return nil
+}
+
+// finish handles finished sequence. Returns to the parent if sequence has been called
+// or starts sequence from the beginning.
+func (s *Sequencer) finish(ctx context.Context, seq *sequence) (response interface{}) {
+	l := logger(ctx)
+	s.record(ctx, seq, HistoryEntry{Kind: HistoryFinished})
+	if seq.status == "" {
+		seq.status = StatusStopped
+	}
+	if seq.parent != "" {
+		l.Debug().Msg("Called sequence is finished. Returning to the parent sequence.")
+		return s.resume(ctx, seq)
+	}
+	s.pushnew(seq.sequenceConfig)
+	l.Debug().Msg("sequence is finished. Creating new one.")
+	if event := s.release(seq); event != nil {
+		s.startQueued(ctx, seq.sequenceConfig, event)
+	}
+	return nil
+}
+
+// callDepth returns number of the sequences in the chain of the callers of the sequence including itself
+func (s *Sequencer) callDepth(seq *sequence) int {
+	depth := 1
+	for id := seq.parent; id != ""; depth++ {
+		caller := s.queue.Get(id)
+		if caller == nil {
+			break
+		}
+		id = caller.parent
+	}
+	return depth
+}
+
+// call starts sequence specified in the call of the current step and suspends the caller until it is finished
+func (s *Sequencer) call(ctx context.Context, parent *sequence) (response interface{}) {
+	l := logger(ctx)
+	call := parent.sequenceConfig.Steps[parent.step].Call
+	l = l.With().Str("call_sequence_name", call.Sequence).Logger()
+	sc, ok := s.sequenceConfig(call.Sequence)
+	if !ok {
+		l.Error().Msg("Cannot find sequence to call, stopping the sequence")
+		return s.finish(ctx, parent)
+	}
+	if depth := s.callDepth(parent); depth >= maxCallDepth {
+		l.Error().Int("call_depth", depth).Msg("Too many nested calls, stopping the sequence")
+		return s.finish(ctx, parent)
+	}
+	input := make(map[string]interface{})
+	if call.Input != nil {
+		value, err := processor.Eval(ctx, parent.processorName(s.Processor), call.Input, parent.payload)
+		if err != nil {
+			l.Error().Err(err).Msg("Cannot evaluate input of the called sequence, stopping the sequence")
+			return s.finish(ctx, parent)
+		}
+		if m, ok := value.(map[string]interface{}); ok {
+			input = m
+		} else if value != nil {
+			l.Error().Msgf("Input of the called sequence should be dict, got %T. Stopping the sequence", value)
+			return s.finish(ctx, parent)
+		}
+	}
+	child := &sequence{
+		id:             s.newID(),
+		sequenceConfig: sc,
+		payload:        &payload.Payload{Env: s.Env, Export: input},
+		parent:         parent.id,
+		started:        now(),
+		trace:          parent.trace,
+	}
+	child.Start(parent.event)
+	s.record(ctx, parent, HistoryEntry{Kind: HistoryCalled, Detail: child.id})
+	//Suspending the caller
+	parent.child = child.id
+	parent.correlation = ""
+	parent.payload.Req = nil
+	parent.payload.Event = nil
+	parent.payload.Resp = nil
+	s.queue.Push(parent)
+
+	l = l.With().
+		Str("sequence_name", sc.Name).
+		Int("sequence_step", child.step).
+		Str("sequence_id", child.id).
+		Str("parent_sequence_id", parent.id).
+		Logger()
+	ctx = l.WithContext(ctx)
+	l.Debug().Msg("Calling sequence")
+	if sc.Steps[0].Call != nil {
+		return s.call(ctx, child)
+	}
+	return s.process(ctx, child)
+}
+
+// resume returns to the parent of the finished called sequence and runs the call step of the parent
+// with export of the called sequence in resp
+func (s *Sequencer) resume(ctx context.Context, child *sequence) (response interface{}) {
+	l := logger(ctx)
+	parent := s.queue.Pop(child.parent)
+	if parent == nil {
+		l.Warn().Str("parent_sequence_id", child.parent).Msg("Cannot find parent of the called sequence")
+		return nil
+	}
+	parent.child = ""
+	parent.event = child.event
+	if child.event != nil {
+		parent.payload.Req = child.event.Data
+		parent.payload.Event = eventInfo(child.event)
+	}
+	parent.payload.Vars = parent.sequenceConfig.Steps[parent.step].Vars
+	parent.payload.Resp = make(map[string]interface{}, len(child.payload.Export)+1)
+	for k, v := range child.payload.Export {
+		parent.payload.Resp[k] = v
+	}
+	parent.payload.Resp["status"] = child.status
+	parent.latestMatch = now()
+	l = l.With().
+		Str("sequence_name", parent.sequenceConfig.Name).
+		Int("sequence_step", parent.step).
+		Str("sequence_id", parent.id).
+		Logger()
+	if parent.parent != "" {
+		l = l.With().Str("parent_sequence_id", parent.parent).Logger()
+	}
+	ctx = l.WithContext(ctx)
+	l.Debug().Str("child_sequence_id", child.id).Msg("Returned from the called sequence")
+	return s.process(ctx, parent)
+}
+
+// sequenceConfig returns config of the sequence with specified name
+func (s *Sequencer) sequenceConfig(name string) (SequenceConfig, bool) {
+	for _, sc := range s.SequenceConfigs {
+		if sc.Name == name {
+			return sc, true
+		}
+	}
+	return SequenceConfig{}, false
+}
+
 // Stop stops Sequencer
 func (s *Sequencer) Stop(ctx context.Context) {
 	l := logger(ctx)
 	l.Info().Msg("Shutting down sequencer")
 	s.stop = true
+	if s.halt != nil {
+		select {
+		case <-s.halt:
+		default:
+			close(s.halt)
+		}
+	}
+	s.stopScheduler()
+	if s.dispatcher != nil {
+		s.dispatcher.stop()
+	}
 	if r := atomic.LoadInt64(&s.running); r != 0 {
 		l.Info().Msgf("Waiting for %d running sequence(s)", r)
 	}
 	s.Lock()
+	//Retried steps release the lock while waiting for the next attempt
+	for atomic.LoadInt64(&s.running) != 0 {
+		s.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		s.Lock()
+	}
 	defer s.Unlock()
 	_ = s.save(ctx)
+	s.flushDeadLetters(ctx)
+	s.flushHistories(ctx)
 }
 
 func (s *Sequencer) newID() string {
@@ -175,25 +611,49 @@ func (s *Sequencer) load(ctx context.Context) error {
 		return nil
 	}
 	var tmp struct {
-		Store *sequenceStack
+		Store    *sequenceStack
+		Disabled []string
 	}
-	tmp.Store = &s.queue
+	tmp.Store = new(sequenceStack)
 	err = json.Unmarshal(buf, &tmp)
 	if err != nil {
 		l.Error().Err(err).Msg("Error on parsing store value")
 		return err
 	}
-	l.Info().Msgf("Found %d unfinished sequence(s)", s.queue.Len(ctx))
+	for _, name := range tmp.Disabled {
+		if s.disabled == nil {
+			s.disabled = make(map[string]bool)
+		}
+		s.disabled[name] = true
+	}
+	saved := tmp.Store.Sequences()
+	l.Info().Msgf("Found %d unfinished sequence(s)", len(saved))
+	sequences := s.reconcile(ctx, saved)
+	//Pushing in reverse order to keep order of the saved stack
+	for i := len(sequences) - 1; i >= 0; i-- {
+		s.queue.Push(sequences[i])
+	}
 	return nil
 }
 
 func (s *Sequencer) save(ctx context.Context) error {
 	l := logger(ctx)
+	//State is saved after every change of the queue so the gauge of waiting instances is updated here
+	s.countWaiting()
+	if s.Store == "" || s.StoreKey == "" {
+		//Sequencer without store keeps the state in memory only
+		return nil
+	}
 	l.Debug().Msg("Saving unfinished sequences to store")
 	var tmp struct {
-		Store *sequenceStack
+		Store    *sequenceStack
+		Disabled []string `json:",omitempty"`
 	}
 	tmp.Store = &s.queue
+	for name := range s.disabled {
+		tmp.Disabled = append(tmp.Disabled, name)
+	}
+	sort.Strings(tmp.Disabled)
 	buf, err := json.Marshal(tmp)
 	if err != nil {
 		l.Error().Err(err).Msg("Error on dumping sequences to JSON")
@@ -215,6 +675,9 @@ func (s *Sequencer) sendToOutput(ctx context.Context, response *payload.Response
 }
 
 func (s *Sequencer) pushnew(sc SequenceConfig) {
+	if sc.Name != "" && s.disabled[sc.Name] {
+		return
+	}
 	seq := &sequence{
 		id:             s.newID(),
 		sequenceConfig: sc,
@@ -0,0 +1,553 @@
+package sequencer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/output"
+	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/report"
+	"github.com/geliar/manopus/pkg/store"
+	_ "github.com/geliar/manopus/pkg/store/memory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testReport struct{}
+
+func (testReport) Type() string                                     { return "test" }
+func (testReport) PushString(ctx context.Context, report string)    {}
+func (testReport) PushReader(ctx context.Context, report io.Reader) {}
+func (testReport) Close(ctx context.Context)                        {}
+
+func init() {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	report.Register(ctx, "test", func(config map[string]interface{}, id string, step int) report.Driver {
+		return testReport{}
+	})
+	report.Init(ctx, report.Config{Driver: "test"})
+	output.Register(ctx, "flaky", flaky)
+}
+
+func testSequencer(ctx context.Context, sequences ...SequenceConfig) *Sequencer {
+	s := &Sequencer{
+		Inputs:          []string{"test"},
+		Processor:       "starlark",
+		SequenceConfigs: sequences,
+	}
+	s.Init(ctx, true)
+	//Timeouts are fired by Roll in tests to keep them deterministic
+	s.stopScheduler()
+	return s
+}
+
+func testEvent(data map[string]interface{}) *payload.Event {
+	return &payload.Event{Input: "test", Type: "test", ID: "test", Data: data}
+}
+
+func TestSequencer_Goto(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	s := testSequencer(ctx, SequenceConfig{
+		Name:   "branching",
+		Single: true,
+		Steps: []StepConfig{
+			{Name: "start", Match: "req['cmd'] == 'start'"},
+			{Name: "decide", Script: "goto('merge') if req['approved'] else goto('notify')"},
+			{Name: "notify", Script: "respond('notified')\nstop()"},
+			{Name: "merge", Script: "respond('merged')"},
+		},
+	})
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "start"})))
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"approved": true})))
+
+	buf, err := json.Marshal(&s.queue)
+	a.NoError(err)
+	var saved []map[string]interface{}
+	a.NoError(json.Unmarshal(buf, &saved))
+	a.Len(saved, 1)
+	a.EqualValues(3, saved[0]["Step"])
+	a.Equal("merge", saved[0]["StepName"])
+
+	a.Equal("merged", s.Roll(ctx, testEvent(map[string]interface{}{})))
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "start"})))
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"approved": false})))
+	a.Equal("notified", s.Roll(ctx, testEvent(map[string]interface{}{})))
+	a.Equal(1, s.queue.Len(ctx))
+}
+
+func TestSequencer_Branches(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	s := testSequencer(ctx, SequenceConfig{
+		Name:   "deploy",
+		Single: true,
+		Steps: []StepConfig{
+			{Name: "start", Match: "req['cmd'] == 'deploy'"},
+			{Name: "approvals", Join: 2, Branches: []BranchConfig{
+				{Name: "qa", Match: "req['from'] == 'qa'", Script: "export['qa'] = req['user']"},
+				{Name: "product", Match: "req['from'] == 'product'", Script: "export['product'] = req['user']"},
+				{Name: "ci", Types: []string{"ci"}, Script: "export['ci'] = 'green'"},
+			}},
+			{Name: "deploy", Script: "respond(','.join(sorted(export.keys())))"},
+		},
+	})
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "deploy"})))
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"from": "qa", "user": "alice"})))
+	//Completed branch should not match anymore
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"from": "qa", "user": "bob"})))
+	seq := s.queue.first.sequence
+	a.Equal(1, seq.step)
+	a.Equal([]string{"qa"}, seq.completed)
+	a.Nil(s.Roll(ctx, &payload.Event{Input: "test", Type: "ci", Data: map[string]interface{}{}}))
+	a.Equal(2, seq.step)
+	a.Empty(seq.completed)
+	a.Equal("ci,qa", s.Roll(ctx, testEvent(map[string]interface{}{})))
+}
+
+func TestSequencer_Call(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	sequences := []SequenceConfig{
+		{
+			Name: "approval",
+			Steps: []StepConfig{
+				{Name: "ask", Types: []string{"never"}, Script: "respond('asking ' + export['approver'])"},
+				{Name: "button", Match: "req['button'] == 'approve'", Script: "export['approved'] = True"},
+			},
+		},
+		{
+			Name:   "deploy",
+			Single: true,
+			Steps: []StepConfig{
+				{Name: "start", Match: "req['cmd'] == 'deploy'"},
+				{
+					Name:   "approve",
+					Call:   &CallConfig{Sequence: "approval", Input: "{'approver': req['user']}"},
+					Script: "respond('deployed' if resp['approved'] else 'rejected')",
+				},
+			},
+		},
+	}
+	s := testSequencer(ctx, sequences...)
+	a.Equal("asking alice", s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "deploy", "user": "alice"})))
+
+	buf, err := json.Marshal(&s.queue)
+	a.NoError(err)
+	var saved []map[string]interface{}
+	a.NoError(json.Unmarshal(buf, &saved))
+	a.Len(saved, 2)
+	a.Equal("approval", saved[0]["SequenceConfig"].(map[string]interface{})["name"])
+	a.Equal(saved[1]["ID"], saved[0]["Parent"])
+	a.Equal(saved[0]["ID"], saved[1]["Child"])
+
+	//Restoring suspended sequences in another sequencer
+	s = testSequencer(ctx, sequences...)
+	a.NoError(json.Unmarshal(buf, &s.queue))
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"button": "cancel"})))
+	a.Equal("deployed", s.Roll(ctx, testEvent(map[string]interface{}{"button": "approve"})))
+	a.Equal(2, s.queue.Len(ctx))
+}
+
+func TestSequencer_CallStatus(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	s := testSequencer(ctx,
+		SequenceConfig{
+			Name: "approval",
+			Steps: []StepConfig{
+				{Name: "ask", Types: []string{"never"}},
+				{Name: "button", Match: "req['button'] == 'approve'", Timeout: 60, Script: "export['approved'] = True"},
+			},
+		},
+		SequenceConfig{
+			Name:   "deploy",
+			Single: true,
+			Steps: []StepConfig{
+				{Name: "start", Match: "req['cmd'] == 'deploy'"},
+				{Name: "approve", Call: &CallConfig{Sequence: "approval"}, Script: "export['outcome'] = resp['status']\nrespond(resp['status'])"},
+			},
+		},
+	)
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "deploy"})))
+	a.Equal(StatusFinished, s.Roll(ctx, testEvent(map[string]interface{}{"button": "approve"})))
+
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "deploy"})))
+	var parent *sequence
+	for _, seq := range s.queue.Sequences() {
+		if seq.sequenceConfig.Name == "approval" && seq.step == 1 {
+			seq.latestMatch = seq.latestMatch.Add(-2 * time.Minute)
+		}
+		if seq.sequenceConfig.Name == "deploy" && seq.step == 1 {
+			parent = seq
+		}
+	}
+	if !a.NotNil(parent) {
+		return
+	}
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{})))
+	a.Equal(StatusTimeout, parent.payload.Export["outcome"])
+}
+
+func TestSequencer_CallCycle(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	sequences := []SequenceConfig{
+		{
+			Name: "ping",
+			Steps: []StepConfig{
+				{Name: "start", Match: "req['cmd'] == 'ping'"},
+				{Name: "pong", Call: &CallConfig{Sequence: "pong"}},
+			},
+		},
+		{
+			Name: "pong",
+			Steps: []StepConfig{
+				{Name: "start", Types: []string{"never"}},
+				{Name: "ping", Call: &CallConfig{Sequence: "ping"}},
+			},
+		},
+	}
+	s := testSequencer(ctx, sequences...)
+	err := s.Validate()
+	if a.Error(err) {
+		a.Contains(err.Error(), "ping -> pong -> ping")
+	}
+
+	//Nested calls are stopped at the depth limit instead of overflowing the stack,
+	//then the callers are resumed one by one
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "ping"})))
+	a.Empty(s.Instances(""))
+	a.Equal(2, s.queue.Len(ctx))
+}
+
+func TestSequencer_OnTimeout(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	s := testSequencer(ctx, SequenceConfig{
+		Name:      "approval",
+		Single:    true,
+		OnTimeout: &HandlerConfig{Script: "export['expired'] = True"},
+		Steps: []StepConfig{
+			{Name: "start", Match: "req['cmd'] == 'approve'"},
+			{
+				Name:      "wait",
+				Match:     "req['button'] == 'approve'",
+				Timeout:   60,
+				OnTimeout: &HandlerConfig{Script: "export['expired'] = req['step_name']", Step: "escalate"},
+			},
+			{Name: "escalate", Match: "req['button'] == 'approve'", Timeout: 60, Script: "respond('approved')"},
+		},
+	})
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "approve"})))
+	seq := s.queue.first.sequence
+	seq.latestMatch = seq.latestMatch.Add(-2 * time.Minute)
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{})))
+	a.Equal(2, seq.step)
+	a.Equal("wait", seq.payload.Export["expired"])
+
+	//Sequence handler finishes the sequence
+	seq.latestMatch = seq.latestMatch.Add(-2 * time.Minute)
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"button": "approve"})))
+	a.Equal(true, seq.payload.Export["expired"])
+	a.Equal(1, s.queue.Len(ctx))
+	a.Equal(0, s.queue.first.sequence.step)
+}
+
+func TestSequencer_Scheduler(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	s := &Sequencer{
+		Inputs:    []string{"test"},
+		Processor: "starlark",
+		SequenceConfigs: []SequenceConfig{{
+			Name:   "approval",
+			Single: true,
+			Steps: []StepConfig{
+				{Name: "start", Match: "req['cmd'] == 'approve'"},
+				{Name: "wait", Timeout: 1, OnTimeout: &HandlerConfig{Script: "export['expired'] = True", Step: "expired"}},
+				{Name: "expired"},
+			},
+		}},
+	}
+	s.Init(ctx, true)
+	defer s.Stop(ctx)
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "approve"})))
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := s.queue.NextDeadline(ctx); !ok {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	seq := s.queue.first.sequence
+	a.Equal(2, seq.step)
+	a.Equal(true, seq.payload.Export["expired"])
+}
+
+func TestSequencer_MaxInstances(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	tests := []struct {
+		policy string
+		//finished result of the event which finishes the first instance
+		finished interface{}
+		//started result of the event which finishes the third instance
+		started interface{}
+		len     int
+	}{
+		{LimitPolicyReject, int64(1), nil, 2},
+		{LimitPolicyEvict, nil, int64(3), 3},
+		{LimitPolicyQueue, int64(1), int64(3), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			a := assert.New(t)
+			s := testSequencer(ctx, SequenceConfig{
+				Name:         "chat",
+				MaxInstances: 2,
+				LimitPolicy:  tt.policy,
+				Steps: []StepConfig{
+					{Name: "start", Match: "req['cmd'] == 'start'", Script: "export['n'] = req['n']"},
+					{Name: "finish", Match: "req['n'] == export['n']", Script: "respond(export['n'])"},
+				},
+			})
+			for n := 1; n <= 3; n++ {
+				a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "start", "n": n})))
+			}
+			a.Equal(tt.finished, s.Roll(ctx, testEvent(map[string]interface{}{"n": 1})))
+			a.Equal(tt.len, s.queue.Len(ctx))
+			a.Equal(tt.started, s.Roll(ctx, testEvent(map[string]interface{}{"n": 3})))
+		})
+	}
+}
+
+func TestSequencer_MaxLifetime(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	s := testSequencer(ctx, SequenceConfig{
+		Name:        "chat",
+		MaxLifetime: 60,
+		OnTimeout:   &HandlerConfig{Script: "export['reason'] = event.type", Step: "never"},
+		Steps: []StepConfig{
+			{Name: "start", Match: "req['cmd'] == 'start'"},
+			{Name: "wait", Match: "False"},
+		},
+	})
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "start"})))
+	a.Equal(2, s.queue.Len(ctx))
+	var seq *sequence
+	for _, sq := range s.queue.Sequences() {
+		if sq.step == 1 {
+			seq = sq
+		}
+	}
+	seq.started = seq.started.Add(-2 * time.Minute)
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{})))
+	a.Equal("lifetime", seq.payload.Export["reason"])
+	a.Equal(1, s.queue.Len(ctx))
+}
+
+// flakyOutput fails the first failures calls
+type flakyOutput struct {
+	failures int
+	calls    int
+}
+
+var flaky = &flakyOutput{}
+
+func (o *flakyOutput) Name() string             { return "flaky" }
+func (o *flakyOutput) Type() string             { return "flaky" }
+func (o *flakyOutput) Stop(ctx context.Context) {}
+func (o *flakyOutput) Send(ctx context.Context, response *payload.Response) map[string]interface{} {
+	o.calls++
+	if o.calls <= o.failures {
+		return nil
+	}
+	return map[string]interface{}{"result": true}
+}
+
+func TestSequencer_Retry(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	sequence := SequenceConfig{
+		Name:   "merge",
+		Single: true,
+		Steps: []StepConfig{{
+			Name:    "merge",
+			Match:   "req['cmd'] == 'merge'",
+			Script:  "if call('flaky', {}) == None:\n  fail('temporary failure')\nrespond('merged')",
+			Retry:   &RetryConfig{Attempts: 3, Backoff: 1, Multiplier: 2, Errors: []string{"temporary"}},
+			OnError: &HandlerConfig{Script: "respond('failed after %d attempts: %s' % (resp['attempts'], resp['error']))"},
+		}},
+	}
+	tests := []struct {
+		name     string
+		failures int
+		calls    int
+		result   interface{}
+	}{
+		{"Success after retries", 2, 3, "merged"},
+		{"Attempts exceeded", 5, 3, "failed after 3 attempts: temporary failure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+			*flaky = flakyOutput{failures: tt.failures}
+			s := testSequencer(ctx, sequence)
+			a.Equal(tt.result, s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "merge"})))
+			a.Equal(tt.calls, flaky.calls)
+		})
+	}
+}
+
+func TestSequencer_RetryUnlocked(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	s := testSequencer(ctx, SequenceConfig{
+		Name: "flaky",
+		Steps: []StepConfig{{
+			Name:   "start",
+			Match:  "req['cmd'] == 'flaky'",
+			Script: "fail('temporary failure')",
+			Retry:  &RetryConfig{Attempts: 2, Backoff: 1000},
+		}},
+	})
+	done := make(chan struct{})
+	go func() {
+		_ = s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "flaky"}))
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	//Admin actions are not blocked by the backoff of the retried step
+	a.NoError(s.SetEnabled(ctx, "flaky", false))
+	select {
+	case <-done:
+		a.Fail("step has been finished before the backoff")
+	default:
+	}
+
+	//Stop interrupts the backoff
+	start := time.Now()
+	s.Stop(ctx)
+	<-done
+	a.True(time.Since(start) < 500*time.Millisecond)
+}
+
+func TestSequencer_ReloadWaitsForRetry(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	config := SequenceConfig{
+		Name: "flaky",
+		Steps: []StepConfig{{
+			Name:   "start",
+			Match:  "req['cmd'] == 'flaky'",
+			Script: "fail('temporary failure')",
+			Retry:  &RetryConfig{Attempts: 2, Backoff: 300},
+		}},
+	}
+	s := testSequencer(ctx, config)
+	defer s.Stop(ctx)
+	done := make(chan struct{})
+	go func() {
+		_ = s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "flaky"}))
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	//Reload does not miss the instance which is taken by the retried step
+	config.Steps = []StepConfig{{Name: "start", Match: "req['cmd'] == 'flaky'", Script: "respond('fixed')"}}
+	a.NoError(s.Reload(ctx, &Sequencer{
+		Inputs:          []string{"test"},
+		Processor:       "starlark",
+		SequenceConfigs: []SequenceConfig{config},
+	}))
+	select {
+	case <-done:
+	default:
+		a.Fail("reload has been finished before the retried step")
+	}
+	a.Equal("fixed", s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "flaky"})))
+}
+
+func TestSequencer_Wait(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	current := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	SetClock(func() time.Time { return current })
+	defer SetClock(nil)
+	if !store.Exists("wait") {
+		store.ConfigureStore(ctx, "wait", store.Config{Type: "memory"})
+	}
+	a.NoError(store.Save(ctx, "wait", "wait", nil))
+	newSequencer := func() *Sequencer {
+		s := &Sequencer{
+			Inputs:    []string{"test"},
+			Processor: "starlark",
+			Store:     "wait",
+			StoreKey:  "wait",
+			SequenceConfigs: []SequenceConfig{{
+				Name: "reminder",
+				Steps: []StepConfig{
+					{Name: "start", Match: "req['cmd'] == 'remind'", Script: "export['at'] = req['at']"},
+					{Name: "delay", Wait: &WaitConfig{Duration: 600}, Script: "export['delayed'] = event.type"},
+					{Name: "until", Wait: &WaitConfig{Until: "export['at']"}},
+					{Name: "done", Match: "False"},
+				},
+			}},
+		}
+		a.NoError(s.Validate())
+		s.Init(ctx, false)
+		return s
+	}
+	s := newSequencer()
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"cmd": "remind", "at": "2019-01-01T01:00:00Z"})))
+	instances := s.Instances("")
+	if a.Len(instances, 1) && a.NotNil(instances[0].WakeAt) {
+		a.Equal("delay", instances[0].StepName)
+		a.Equal(current.Add(10*time.Minute), *instances[0].WakeAt)
+	}
+	//Wait step does not match events
+	a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{})))
+	current = current.Add(9 * time.Minute)
+	s.Sweep(ctx)
+	a.Equal("delay", s.Instances("")[0].StepName)
+
+	//Waiting sequence is resumed after restart
+	s.Stop(ctx)
+	current = current.Add(time.Minute + time.Second)
+	s = newSequencer()
+	defer s.Stop(ctx)
+	s.Sweep(ctx)
+	instances = s.Instances("")
+	if a.Len(instances, 1) && a.NotNil(instances[0].WakeAt) {
+		a.Equal("until", instances[0].StepName)
+		a.Equal("wait", instances[0].Export["delayed"])
+		a.Equal(time.Date(2019, 1, 1, 1, 0, 0, 0, time.UTC), instances[0].WakeAt.UTC())
+	}
+	current = time.Date(2019, 1, 1, 1, 0, 1, 0, time.UTC)
+	s.Sweep(ctx)
+	instances = s.Instances("")
+	if a.Len(instances, 1) {
+		a.Equal("done", instances[0].StepName)
+		a.Nil(instances[0].WakeAt)
+	}
+}
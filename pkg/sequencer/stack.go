@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"reflect"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/geliar/manopus/pkg/payload"
 )
@@ -13,10 +15,36 @@ type contextElement struct {
 	previous *contextElement
 	next     *contextElement
 	sequence *sequence
+	order    uint64
+	routes   []route
+	group    correlationGroup
+	key      string
+}
+
+// route describes pair of input and event type the sequence is waiting for.
+// Empty eventType means any type of event from the input.
+type route struct {
+	input     string
+	eventType string
+}
+
+// correlationGroup identifies the step of the sequence which waits for events with correlation key
+type correlationGroup struct {
+	sequence   string
+	step       int
+	expression string
 }
 
 type sequenceStack struct {
 	first *contextElement
+	//inputs the list of inputs which should be matched if inputs list in step and sequence is empty
+	inputs     []string
+	index      map[string]map[string]map[*contextElement]struct{}
+	correlated map[correlationGroup]map[string]map[*contextElement]struct{}
+	ids        map[string]*contextElement
+	order      uint64
+	//fallback name of the sequence which templates are matched only with events which matched no other sequence
+	fallback string
 	sync.RWMutex
 }
 
@@ -40,21 +68,192 @@ func (s *sequenceStack) Exists(sequence *sequence) bool {
 	return s.exists(sequence)
 }
 
-// Match matching event with sequences in stack, pops and returns first matched sequence
-func (s *sequenceStack) Match(ctx context.Context, inputs []string, processorName string, event *payload.Event) (sequences []*sequence) {
+// Pop removes sequence with specified ID from the stack and returns it.
+// Returns nil if there is no such sequence.
+func (s *sequenceStack) Pop(id string) *sequence {
 	s.Lock()
 	defer s.Unlock()
+	elem, ok := s.ids[id]
+	if !ok {
+		return nil
+	}
+	s.pop(elem)
+	return elem.sequence
+}
+
+// Get returns sequence with specified ID without removing it from the stack.
+// Returns nil if there is no such sequence.
+func (s *sequenceStack) Get(id string) *sequence {
+	s.RLock()
+	defer s.RUnlock()
+	elem, ok := s.ids[id]
+	if !ok {
+		return nil
+	}
+	return elem.sequence
+}
+
+// PopTemplate removes template of the sequence with specified name from the stack and returns it.
+// Returns nil if there is no such template.
+func (s *sequenceStack) PopTemplate(name string) *sequence {
+	s.Lock()
+	defer s.Unlock()
+	for elem := s.first; elem != nil; elem = elem.next {
+		if elem.sequence.template() && elem.sequence.sequenceConfig.Name == name {
+			s.pop(elem)
+			return elem.sequence
+		}
+	}
+	return nil
+}
+
+// Oldest returns the earliest started instance of the sequence with specified name which is not called by another sequence
+func (s *sequenceStack) Oldest(name string) (oldest *sequence) {
+	s.RLock()
+	defer s.RUnlock()
 	elem := s.first
 	for elem != nil {
-		if elem.sequence.Match(ctx, inputs, processorName, event) {
-			s.pop(elem)
-			sequences = append(sequences, elem.sequence)
+		seq := elem.sequence
+		if seq.sequenceConfig.Name == name && seq.parent == "" && !seq.started.IsZero() &&
+			(oldest == nil || seq.started.Before(oldest.started)) {
+			oldest = seq
 		}
 		elem = elem.next
 	}
 	return
 }
 
+// Reset removes all sequences from the stack and returns them
+func (s *sequenceStack) Reset() (sequences []*sequence) {
+	s.Lock()
+	defer s.Unlock()
+	elem := s.first
+	for elem != nil {
+		sequences = append(sequences, elem.sequence)
+		elem = elem.next
+	}
+	s.first = nil
+	s.index = nil
+	s.correlated = nil
+	s.ids = nil
+	return
+}
+
+// Sequences returns all sequences in the stack
+func (s *sequenceStack) Sequences() (sequences []*sequence) {
+	s.RLock()
+	defer s.RUnlock()
+	elem := s.first
+	for elem != nil {
+		sequences = append(sequences, elem.sequence)
+		elem = elem.next
+	}
+	return
+}
+
+// Match matching event with candidate sequences in stack, pops and returns matched sequences
+func (s *sequenceStack) Match(ctx context.Context, processorName string, event *payload.Event) (sequences []*sequence) {
+	sequences, _ = s.MatchSequence(ctx, processorName, event, "")
+	return
+}
+
+// MatchSequence matching event with candidate sequences in stack which have specified name,
+// pops and returns matched sequences. Empty name matches sequences with any name except templates
+// of the fallback sequence. Candidates are matched in order of priority and the match of exclusive
+// sequence stops matching. Returns the reason if the event has not matched any sequence.
+// Match scripts are evaluated on the copies of the candidates without holding the locks, so matched
+// sequence is taken only if it is still in the stack.
+func (s *sequenceStack) MatchSequence(ctx context.Context, processorName string, event *payload.Event, name string) (sequences []*sequence, reason unmatched) {
+	keys := s.correlationKeys(ctx, processorName, event)
+	s.RLock()
+	inputs := s.inputs
+	var pending []candidate
+	for _, elem := range s.candidates(ctx, event, keys) {
+		if name != "" && elem.sequence.sequenceConfig.Name != name {
+			continue
+		}
+		if name == "" && s.isFallback(elem) {
+			continue
+		}
+		pending = append(pending, candidate{elem: elem, sequence: elem.sequence.snapshot()})
+	}
+	s.RUnlock()
+	evaluated := 0
+	for len(pending) > 0 {
+		var matched []candidate
+		running := unlocked(ctx, func(<-chan struct{}) {
+			for len(pending) > 0 {
+				c := pending[0]
+				pending = pending[1:]
+				evaluated++
+				ok, err := c.sequence.match(ctx, inputs, processorName, event)
+				if err != nil {
+					reason.err = err
+				}
+				if ok {
+					matched = append(matched, c)
+					if c.sequence.sequenceConfig.Exclusive {
+						return
+					}
+				}
+			}
+		})
+		if !running {
+			break
+		}
+		if s.take(ctx, matched, &sequences) {
+			break
+		}
+	}
+	if len(sequences) > 0 {
+		return sequences, unmatched{}
+	}
+	s.RLock()
+	defer s.RUnlock()
+	switch {
+	case reason.err != nil:
+		reason.reason = UnmatchedError
+	case evaluated > 0:
+		reason.reason = UnmatchedScript
+	case s.waitsFor(event.Input):
+		reason.reason = UnmatchedType
+	default:
+		reason.reason = UnmatchedInput
+	}
+	return
+}
+
+// candidate copy of the sequence from the stack which is matched with the event without holding the lock
+type candidate struct {
+	elem     *contextElement
+	sequence *sequence
+}
+
+// take pops matched candidates which are still in the stack and appends them to sequences.
+// Candidates which have been taken by another event or removed meanwhile are skipped.
+// Returns true if the event has been consumed by exclusive sequence.
+func (s *sequenceStack) take(ctx context.Context, matched []candidate, sequences *[]*sequence) bool {
+	s.Lock()
+	defer s.Unlock()
+	for _, c := range matched {
+		if s.ids[c.sequence.id] != c.elem {
+			continue
+		}
+		c.elem.sequence.take(c.sequence)
+		s.pop(c.elem)
+		*sequences = append(*sequences, c.elem.sequence)
+		if c.sequence.sequenceConfig.Exclusive {
+			l := logger(ctx)
+			l.Debug().
+				Str("sequence_name", c.sequence.sequenceConfig.Name).
+				Str("sequence_id", c.sequence.id).
+				Msg("Event consumed by exclusive sequence")
+			return true
+		}
+	}
+	return false
+}
+
 func (s *sequenceStack) GC(ctx context.Context) (sequences []*sequence) {
 	s.Lock()
 	defer s.Unlock()
@@ -69,6 +268,22 @@ func (s *sequenceStack) GC(ctx context.Context) (sequences []*sequence) {
 	return
 }
 
+// NextDeadline returns the nearest deadline of the sequences in stack.
+// Returns false if there are no sequences with timeout.
+func (s *sequenceStack) NextDeadline(ctx context.Context) (next time.Time, ok bool) {
+	s.RLock()
+	defer s.RUnlock()
+	elem := s.first
+	for elem != nil {
+		if deadline, has := elem.sequence.Deadline(); has && (!ok || deadline.Before(next)) {
+			next = deadline
+			ok = true
+		}
+		elem = elem.next
+	}
+	return
+}
+
 func (s *sequenceStack) Len(ctx context.Context) (len int) {
 	s.RLock()
 	defer s.RUnlock()
@@ -80,6 +295,90 @@ func (s *sequenceStack) Len(ctx context.Context) (len int) {
 	return
 }
 
+// isFallback checks if element is the template of the fallback sequence.
+// Such templates are matched only with events which matched no other sequence.
+func (s *sequenceStack) isFallback(elem *contextElement) bool {
+	return s.fallback != "" && elem.sequence.sequenceConfig.Name == s.fallback && elem.sequence.template()
+}
+
+// waitsFor checks if any sequence except templates of the fallback sequence waits for the events of the input
+func (s *sequenceStack) waitsFor(input string) bool {
+	for _, elems := range s.index[input] {
+		for elem := range elems {
+			if !s.isFallback(elem) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// candidates returns elements which are waiting for the input and type of the event
+// and elements which are waiting for the evaluated correlation keys of the event.
+// Elements are returned sorted by priority, then in the stack order (latest pushed first).
+// Warning: candidates is not thread-safe sequenceStack should be locked before use
+func (s *sequenceStack) candidates(ctx context.Context, event *payload.Event, keys map[correlationGroup]string) (elems []*contextElement) {
+	types := s.index[event.Input]
+	for elem := range types[event.Type] {
+		elems = append(elems, elem)
+	}
+	if event.Type != "" {
+		for elem := range types[""] {
+			//Element can be indexed with both specific and any type when its step has branches
+			if _, ok := types[event.Type][elem]; !ok {
+				elems = append(elems, elem)
+			}
+		}
+	}
+	l := logger(ctx)
+	for group, key := range keys {
+		for elem := range s.correlated[group][key] {
+			l.Debug().
+				Str("sequence_name", elem.sequence.sequenceConfig.Name).
+				Str("sequence_id", elem.sequence.id).
+				Str("sequence_correlation", key).
+				Msg("Event routed by correlation key")
+			elems = append(elems, elem)
+		}
+	}
+	sort.Slice(elems, func(i, j int) bool {
+		pi, pj := elems[i].sequence.sequenceConfig.Priority, elems[j].sequence.sequenceConfig.Priority
+		if pi != pj {
+			return pi > pj
+		}
+		return elems[i].order > elems[j].order
+	})
+	return
+}
+
+// correlationKeys evaluates correlation keys of the event for the steps waiting for the input
+// and type of the event. Expressions are evaluated on the copies of the sequences without holding the locks.
+func (s *sequenceStack) correlationKeys(ctx context.Context, processorName string, event *payload.Event) map[correlationGroup]string {
+	s.RLock()
+	samples := make(map[correlationGroup]*sequence)
+	for group, keys := range s.correlated {
+		//All elements of the group share the same step config so any of them can evaluate the key
+		sample := anyElement(keys)
+		if sample == nil || !sample.sequence.accepts(s.inputs, event) {
+			continue
+		}
+		samples[group] = sample.sequence.snapshot()
+	}
+	s.RUnlock()
+	if len(samples) == 0 {
+		return nil
+	}
+	keys := make(map[correlationGroup]string, len(samples))
+	unlocked(ctx, func(<-chan struct{}) {
+		for group, sample := range samples {
+			if key := sample.EventCorrelation(ctx, processorName, event); key != "" {
+				keys[group] = key
+			}
+		}
+	})
+	return keys
+}
+
 // pop removes element from stack.
 // Warning: pop is not thread-safe sequenceStack should be locked before use
 func (s *sequenceStack) pop(elem *contextElement) {
@@ -92,16 +391,97 @@ func (s *sequenceStack) pop(elem *contextElement) {
 	if s.first == elem {
 		s.first = elem.next
 	}
+	elem.next = nil
+	elem.previous = nil
+	if s.ids[elem.sequence.id] == elem {
+		delete(s.ids, elem.sequence.id)
+	}
+	s.unindex(elem)
 }
 
 // push adds element to the beginning of the stack.
 // Warning: push is not thread-safe sequenceStack should be locked before use
 func (s *sequenceStack) push(sequence *sequence) {
-	e := &contextElement{next: s.first, sequence: sequence}
+	s.order++
+	e := &contextElement{next: s.first, sequence: sequence, order: s.order}
 	if s.first != nil {
 		s.first.previous = e
 	}
 	s.first = e
+	if s.ids == nil {
+		s.ids = make(map[string]*contextElement)
+	}
+	s.ids[sequence.id] = e
+	s.reindex(e)
+}
+
+// reindex adds element to the index with its current routes or correlation key.
+// Warning: reindex is not thread-safe sequenceStack should be locked before use
+func (s *sequenceStack) reindex(elem *contextElement) {
+	s.unindex(elem)
+	if key := elem.sequence.correlation; key != "" {
+		if s.correlated == nil {
+			s.correlated = make(map[correlationGroup]map[string]map[*contextElement]struct{})
+		}
+		elem.group = elem.sequence.correlationGroup()
+		elem.key = key
+		keys, ok := s.correlated[elem.group]
+		if !ok {
+			keys = make(map[string]map[*contextElement]struct{})
+			s.correlated[elem.group] = keys
+		}
+		elems, ok := keys[key]
+		if !ok {
+			elems = make(map[*contextElement]struct{})
+			keys[key] = elems
+		}
+		elems[elem] = struct{}{}
+		return
+	}
+	elem.routes = elem.sequence.routes(s.inputs)
+	if s.index == nil {
+		s.index = make(map[string]map[string]map[*contextElement]struct{})
+	}
+	for _, r := range elem.routes {
+		types, ok := s.index[r.input]
+		if !ok {
+			types = make(map[string]map[*contextElement]struct{})
+			s.index[r.input] = types
+		}
+		elems, ok := types[r.eventType]
+		if !ok {
+			elems = make(map[*contextElement]struct{})
+			types[r.eventType] = elems
+		}
+		elems[elem] = struct{}{}
+	}
+}
+
+// unindex removes element from the index.
+// Warning: unindex is not thread-safe sequenceStack should be locked before use
+func (s *sequenceStack) unindex(elem *contextElement) {
+	for _, r := range elem.routes {
+		types := s.index[r.input]
+		delete(types[r.eventType], elem)
+		if len(types[r.eventType]) == 0 {
+			delete(types, r.eventType)
+		}
+		if len(types) == 0 {
+			delete(s.index, r.input)
+		}
+	}
+	elem.routes = nil
+	if elem.key != "" {
+		keys := s.correlated[elem.group]
+		delete(keys[elem.key], elem)
+		if len(keys[elem.key]) == 0 {
+			delete(keys, elem.key)
+		}
+		if len(keys) == 0 {
+			delete(s.correlated, elem.group)
+		}
+		elem.key = ""
+	}
 }
 
 // exists checks existence of the element in the stack.
@@ -109,7 +489,7 @@ func (s *sequenceStack) push(sequence *sequence) {
 func (s *sequenceStack) exists(sequence *sequence) bool {
 	elem := s.first
 	for elem != nil {
-		if reflect.DeepEqual(elem.sequence.sequenceConfig, sequence.sequenceConfig) &&
+		if elem.sequence.template() && sameSequence(elem.sequence, sequence) &&
 			elem.sequence.step == sequence.step {
 			return true
 		}
@@ -124,7 +504,7 @@ func (s *sequenceStack) MarshalJSON() ([]byte, error) {
 	var v []sequence
 	elem := s.first
 	for elem != nil {
-		if elem.sequence.step != 0 {
+		if elem.sequence.step != 0 || elem.sequence.parent != "" || elem.sequence.child != "" {
 			v = append(v, *(elem.sequence))
 		}
 		elem = elem.next
@@ -141,8 +521,27 @@ func (s *sequenceStack) UnmarshalJSON(buf []byte) (err error) {
 	if err != nil {
 		return
 	}
-	for _, e := range v {
-		s.push(&e)
+	//Pushing in reverse order to keep order of the saved stack
+	for i := len(v) - 1; i >= 0; i-- {
+		s.push(&v[i])
 	}
 	return
 }
+
+func anyElement(keys map[string]map[*contextElement]struct{}) *contextElement {
+	for _, elems := range keys {
+		for elem := range elems {
+			return elem
+		}
+	}
+	return nil
+}
+
+// sameSequence checks if both sequences are instances of the same sequence definition.
+// Sequences are compared by name or by config if they have no name.
+func sameSequence(a, b *sequence) bool {
+	if a.sequenceConfig.Name != "" || b.sequenceConfig.Name != "" {
+		return a.sequenceConfig.Name == b.sequenceConfig.Name
+	}
+	return reflect.DeepEqual(a.sequenceConfig, b.sequenceConfig)
+}
@@ -0,0 +1,288 @@
+package sequencer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/processor"
+	_ "github.com/geliar/manopus/pkg/processor/starlark"
+	"github.com/geliar/manopus/pkg/report"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testSequence(id string, step int, steps ...StepConfig) *sequence {
+	return &sequence{
+		id:             id,
+		sequenceConfig: SequenceConfig{Name: id, Steps: steps},
+		step:           step,
+		payload:        &payload.Payload{},
+	}
+}
+
+func sequenceIDs(sequences []*sequence) (ids []string) {
+	for _, s := range sequences {
+		ids = append(ids, s.id)
+	}
+	return
+}
+
+func TestSequenceStack_Match(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	tests := []struct {
+		name  string
+		event payload.Event
+		out   []string
+	}{
+		{"Input and type", payload.Event{Input: "slack", Type: "interaction", Data: "data"}, []string{"script", "any_type", "interaction", "default_input"}},
+		{"Type filtered", payload.Event{Input: "slack", Type: "event", Data: "data"}, []string{"script", "any_type", "default_input"}},
+		{"Sequence inputs", payload.Event{Input: "github", Type: "push", Data: "data"}, []string{"sequence_input"}},
+		{"Unknown input", payload.Event{Input: "timer", Type: "timer", Data: "data"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+			s := sequenceStack{inputs: []string{"slack"}}
+			s.Push(testSequence("default_input", 0, StepConfig{}))
+			s.Push(testSequence("interaction", 0, StepConfig{Inputs: []string{"slack"}, Types: []string{"interaction"}}))
+			seq := testSequence("sequence_input", 0, StepConfig{})
+			seq.sequenceConfig.Inputs = []string{"github"}
+			s.Push(seq)
+			s.Push(testSequence("any_type", 0, StepConfig{Inputs: []string{"slack", "http"}}))
+			s.Push(testSequence("no_match", 0, StepConfig{Match: "False"}))
+			s.Push(testSequence("script", 0, StepConfig{Match: "True"}))
+
+			a.Equal(tt.out, sequenceIDs(s.Match(ctx, "starlark", &tt.event)))
+			a.Equal(6-len(tt.out), s.Len(ctx))
+		})
+	}
+}
+
+func TestSequenceStack_Priority(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	s := sequenceStack{inputs: []string{"slack"}}
+	push := func(id string, priority int, exclusive bool, match string) {
+		seq := testSequence(id, 0, StepConfig{Match: match})
+		seq.sequenceConfig.Priority = priority
+		seq.sequenceConfig.Exclusive = exclusive
+		s.Push(seq)
+	}
+	push("catch_all", -1, false, "True")
+	push("generic", 0, false, "True")
+	push("command", 10, true, "req == 'deploy'")
+	push("audit", 20, false, "True")
+
+	event := &payload.Event{Input: "slack", Type: "message", Data: "deploy"}
+	a.Equal([]string{"audit", "command"}, sequenceIDs(s.Match(ctx, "starlark", event)))
+	event = &payload.Event{Input: "slack", Type: "message", Data: "hello"}
+	a.Equal([]string{"generic", "catch_all"}, sequenceIDs(s.Match(ctx, "starlark", event)))
+}
+
+func TestSequenceStack_Reindex(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	s := sequenceStack{inputs: []string{"slack"}}
+	seq := testSequence("seq", 0,
+		StepConfig{Types: []string{"event"}},
+		StepConfig{Inputs: []string{"timer"}},
+	)
+	s.Push(seq)
+	a.Equal([]string{"seq"}, sequenceIDs(s.Match(ctx, "starlark", &payload.Event{Input: "slack", Type: "event"})))
+	a.Empty(s.index)
+	seq.step++
+	s.Push(seq)
+	a.Empty(s.Match(ctx, "starlark", &payload.Event{Input: "slack", Type: "event"}))
+	a.Equal([]string{"seq"}, sequenceIDs(s.Match(ctx, "starlark", &payload.Event{Input: "timer", Type: "timer"})))
+	a.Empty(s.index)
+}
+
+func TestSequenceStack_Correlation(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	s := sequenceStack{inputs: []string{"slack"}}
+	steps := []StepConfig{
+		{},
+		{Correlation: "req['user']", Match: "True"},
+	}
+	for _, user := range []string{"first", "second", "third"} {
+		seq := testSequence(user, 1, steps...)
+		seq.payload.Req = map[string]interface{}{"user": user}
+		seq.payload.Event = &payload.EventInfo{Input: "slack"}
+		seq.Correlate(ctx, "starlark")
+		a.Equal(user, seq.correlation)
+		s.Push(seq)
+	}
+	a.Empty(s.index)
+	a.Empty(s.Match(ctx, "starlark", &payload.Event{Input: "slack", Data: map[string]interface{}{"user": "unknown"}}))
+	a.Empty(s.Match(ctx, "starlark", &payload.Event{Input: "github", Data: map[string]interface{}{"user": "second"}}))
+	a.Equal([]string{"second"}, sequenceIDs(s.Match(ctx, "starlark", &payload.Event{Input: "slack", Data: map[string]interface{}{"user": "second"}})))
+	a.Equal(2, s.Len(ctx))
+}
+
+// linearStack is the sequence stack before indexing, which matches the event with every sequence in the stack.
+// It is used as a baseline for the benchmarks.
+type linearStack struct {
+	first *contextElement
+	sync.RWMutex
+}
+
+func (s *linearStack) Push(sequence *sequence) {
+	s.Lock()
+	defer s.Unlock()
+	e := &contextElement{next: s.first, sequence: sequence}
+	if s.first != nil {
+		s.first.previous = e
+	}
+	s.first = e
+}
+
+// Match matching event with sequences in stack, pops and returns first matched sequence
+func (s *linearStack) Match(ctx context.Context, inputs []string, processorName string, event *payload.Event) (sequences []*sequence) {
+	s.Lock()
+	defer s.Unlock()
+	elem := s.first
+	for elem != nil {
+		if elem.sequence.Match(ctx, inputs, processorName, event) {
+			s.pop(elem)
+			sequences = append(sequences, elem.sequence)
+		}
+		elem = elem.next
+	}
+	return
+}
+
+func (s *linearStack) pop(elem *contextElement) {
+	if elem.next != nil {
+		elem.next.previous = elem.previous
+	}
+	if elem.previous != nil {
+		elem.previous.next = elem.next
+	}
+	if s.first == elem {
+		s.first = elem.next
+	}
+}
+
+// benchmarkSequences returns waiting sequences which do not match the benchmark event and the sequence which
+// waits for the event of the same type
+func benchmarkSequences(waiting int) (sequences []*sequence) {
+	for i := 0; i < waiting; i++ {
+		var step StepConfig
+		switch i % 3 {
+		case 0:
+			step = StepConfig{Types: []string{"interaction"}, Match: "req.callback_id == 'never'"}
+		case 1:
+			step = StepConfig{Inputs: []string{"github"}, Match: "req.number == 0"}
+		case 2:
+			step = StepConfig{Inputs: []string{"timer"}, Match: "req.timer_id == 'never'"}
+		}
+		sequences = append(sequences, testSequence(fmt.Sprintf("waiting-%d", i), 0, step))
+	}
+	return append(sequences, testSequence("message", 0, StepConfig{Types: []string{"event"}, Match: "req == None"}))
+}
+
+func benchmarkMatch(b *testing.B, match func(context.Context, string, *payload.Event) []*sequence) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	event := &payload.Event{Input: "slack", Type: "event", Data: "some data"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(match(ctx, "starlark", event)) != 0 {
+			b.Fatal("event should not be matched")
+		}
+	}
+}
+
+func BenchmarkSequenceStack_Match(b *testing.B) {
+	inputs := []string{"slack"}
+	for _, waiting := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("Indexed-%d", waiting), func(b *testing.B) {
+			s := &sequenceStack{inputs: inputs}
+			for _, seq := range benchmarkSequences(waiting) {
+				s.Push(seq)
+			}
+			benchmarkMatch(b, s.Match)
+		})
+		b.Run(fmt.Sprintf("Linear-%d", waiting), func(b *testing.B) {
+			s := &linearStack{}
+			for _, seq := range benchmarkSequences(waiting) {
+				s.Push(seq)
+			}
+			benchmarkMatch(b, func(ctx context.Context, processorName string, event *payload.Event) []*sequence {
+				return s.Match(ctx, inputs, processorName, event)
+			})
+		})
+	}
+}
+
+// blockingProcessor matches events when release channel is closed
+type blockingProcessor struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProcessor) Type() string { return "blocking" }
+
+func (p *blockingProcessor) Run(ctx context.Context, reporter report.Driver, script interface{}, event *payload.Event, payload *payload.Payload) (next processor.NextStatus, callback interface{}, responses []payload.Response, err error) {
+	return
+}
+
+func (p *blockingProcessor) Match(ctx context.Context, match interface{}, payload *payload.Payload) (matched bool, err error) {
+	p.entered <- struct{}{}
+	<-p.release
+	return true, nil
+}
+
+func (p *blockingProcessor) Eval(ctx context.Context, expression interface{}, payload *payload.Payload) (value interface{}, err error) {
+	return
+}
+
+func TestSequenceStack_MatchUnlocked(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	p := &blockingProcessor{entered: make(chan struct{}, 1), release: make(chan struct{})}
+	processor.Register(ctx, p)
+	s := testSequencer(ctx, SequenceConfig{
+		Name:      "slow",
+		Processor: "blocking",
+		Steps:     []StepConfig{{Name: "start", Match: "block"}},
+	})
+	defer s.Stop(ctx)
+	done := make(chan []*sequence)
+	go func() {
+		s.RLock()
+		defer s.RUnlock()
+		sequences, _ := s.queue.MatchSequence(s.withReadLock(ctx), "", testEvent(nil), "")
+		done <- sequences
+	}()
+	<-p.entered
+
+	//Stack and Sequencer are not locked while the match script is running
+	finished := make(chan struct{})
+	go func() {
+		a.NoError(s.SetEnabled(ctx, "slow", false))
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		a.Fail("admin action is blocked by the match script")
+	}
+
+	//Template removed while being matched is not taken
+	close(p.release)
+	a.Empty(<-done)
+	a.Equal(0, s.queue.Len(ctx))
+}
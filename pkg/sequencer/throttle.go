@@ -0,0 +1,229 @@
+package sequencer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/geliar/manopus/pkg/metrics"
+	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/processor"
+)
+
+const (
+	//suppressedRateLimit reason of the event suppressed by rate limit
+	suppressedRateLimit = "rate_limit"
+	//suppressedDebounce reason of the event collapsed by debounce
+	suppressedDebounce = "debounce"
+	//defaultRatePeriod default period (in seconds) of the rate limit
+	defaultRatePeriod = 60
+	//maxBuckets number of the rate limit buckets after which full buckets are removed
+	maxBuckets = 10000
+)
+
+var metricSuppressed = metrics.NewCounter("manopus_sequence_suppressed_total",
+	"Number of events which have not started the sequence because of rate limit or debounce.", "sequence", "reason")
+
+// throttle keeps state of rate limits and debounced events of the sequences
+type throttle struct {
+	buckets   map[string]*bucket
+	debounced map[string]*debounced
+	sync.Mutex
+}
+
+// bucket token bucket of the rate limit
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// debounced the latest event which starts the sequence after the debounce window
+type debounced struct {
+	sc       SequenceConfig
+	event    *payload.Event
+	deadline time.Time
+}
+
+// suppress applies debounce and rate limit of the sequence to the event which starts the new instance.
+// Returns true and the message for the callback if the instance should not be started now.
+func (s *Sequencer) suppress(ctx context.Context, seq *sequence, event *payload.Event) (bool, interface{}) {
+	sc := &seq.sequenceConfig
+	if sc.Debounce != nil {
+		s.debounce(ctx, seq, event)
+		//Returning the template of the sequence back to the queue
+		s.pushnew(*sc)
+		return true, sc.Debounce.Message
+	}
+	if s.rateLimited(ctx, seq, event) {
+		s.pushnew(*sc)
+		return true, sc.RateLimit.Message
+	}
+	return false, nil
+}
+
+// rateLimited takes the token from the bucket of the event. Returns true if the bucket is empty.
+func (s *Sequencer) rateLimited(ctx context.Context, seq *sequence, event *payload.Event) bool {
+	rl := seq.sequenceConfig.RateLimit
+	if rl == nil || rl.Rate <= 0 {
+		return false
+	}
+	name := seq.sequenceConfig.Name
+	key := s.throttleKey(ctx, seq, rl.Key, event)
+	period := rl.Period
+	if period <= 0 {
+		period = defaultRatePeriod
+	}
+	burst := float64(rl.Burst)
+	if burst <= 0 {
+		burst = float64(rl.Rate)
+	}
+	perSecond := float64(rl.Rate) / float64(period)
+	current := now()
+
+	s.throttle.Lock()
+	if s.throttle.buckets == nil {
+		s.throttle.buckets = make(map[string]*bucket)
+	}
+	if len(s.throttle.buckets) > maxBuckets {
+		for k, b := range s.throttle.buckets {
+			if b.tokens+current.Sub(b.updated).Seconds()*perSecond >= burst {
+				delete(s.throttle.buckets, k)
+			}
+		}
+	}
+	b, ok := s.throttle.buckets[name+"\xff"+key]
+	if !ok {
+		b = &bucket{tokens: burst, updated: current}
+		s.throttle.buckets[name+"\xff"+key] = b
+	}
+	b.tokens += current.Sub(b.updated).Seconds() * perSecond
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.updated = current
+	limited := b.tokens < 1
+	if !limited {
+		b.tokens--
+	}
+	s.throttle.Unlock()
+
+	if limited {
+		metricSuppressed.Inc(name, suppressedRateLimit)
+		l := logger(ctx)
+		l.Warn().
+			Str("rate_limit_key", key).
+			Int("rate_limit", rl.Rate).
+			Int64("rate_limit_period", period).
+			Msg("Sequence reached rate limit. Ignoring the event.")
+	}
+	return limited
+}
+
+// debounce holds the event until the debounce window of its key passes without new events.
+// Event held before is replaced with the new one.
+func (s *Sequencer) debounce(ctx context.Context, seq *sequence, event *payload.Event) {
+	l := logger(ctx)
+	sc := seq.sequenceConfig
+	key := s.throttleKey(ctx, seq, sc.Debounce.Key, event)
+	s.throttle.Lock()
+	if s.throttle.debounced == nil {
+		s.throttle.debounced = make(map[string]*debounced)
+	}
+	_, replaced := s.throttle.debounced[sc.Name+"\xff"+key]
+	s.throttle.debounced[sc.Name+"\xff"+key] = &debounced{
+		sc:       sc,
+		event:    event,
+		deadline: now().Add(time.Duration(sc.Debounce.Window) * time.Second),
+	}
+	s.throttle.Unlock()
+	if replaced {
+		metricSuppressed.Inc(sc.Name, suppressedDebounce)
+		l.Debug().Str("debounce_key", key).Msg("Debounced event has been replaced with the new one")
+		return
+	}
+	l.Debug().Str("debounce_key", key).Msg("Event is held until the end of debounce window")
+}
+
+// nextDebounce returns the nearest deadline of the debounced events
+func (s *Sequencer) nextDebounce() (next time.Time, ok bool) {
+	s.throttle.Lock()
+	defer s.throttle.Unlock()
+	for _, d := range s.throttle.debounced {
+		if !ok || d.deadline.Before(next) {
+			next = d.deadline
+			ok = true
+		}
+	}
+	return
+}
+
+// startDebounced starts the sequences with the debounced events which windows have passed.
+// Returns true if any event has been processed.
+func (s *Sequencer) startDebounced(ctx context.Context) (started bool) {
+	current := now()
+	var due []*debounced
+	s.throttle.Lock()
+	for k, d := range s.throttle.debounced {
+		if !d.deadline.After(current) {
+			due = append(due, d)
+			delete(s.throttle.debounced, k)
+		}
+	}
+	s.throttle.Unlock()
+	for _, d := range due {
+		seq := &sequence{
+			id:             s.newID(),
+			sequenceConfig: d.sc,
+			payload:        &payload.Payload{Env: s.Env},
+		}
+		l := logger(ctx).With().
+			Str("sequence_name", d.sc.Name).
+			Str("sequence_id", seq.id).
+			Str("event_id", d.event.ID).
+			Logger()
+		ctx := l.WithContext(mergeContexts(s.mainCtx, ctx))
+		if !seq.Match(ctx, s.queue.inputs, s.Processor, d.event) {
+			l.Debug().Msg("Debounced event does not match the sequence anymore")
+			continue
+		}
+		if d.sc.Single && s.queue.PopTemplate(d.sc.Name) == nil {
+			l.Debug().Msg("Single sequence is already running. Ignoring the debounced event.")
+			continue
+		}
+		if s.rateLimited(ctx, seq, d.event) {
+			s.pushnew(d.sc)
+			continue
+		}
+		if !s.admit(ctx, seq, d.event) {
+			continue
+		}
+		l.Debug().Msg("Starting sequence with the debounced event")
+		if seq.sequenceConfig.Steps[0].Call != nil {
+			_ = s.call(ctx, seq)
+		} else {
+			_ = s.process(ctx, seq)
+		}
+		started = true
+	}
+	return
+}
+
+// throttleKey evaluates key expression of the rate limit or debounce on the event
+func (s *Sequencer) throttleKey(ctx context.Context, seq *sequence, expression interface{}, event *payload.Event) string {
+	if expression == nil {
+		return ""
+	}
+	p := &payload.Payload{
+		Env:   s.Env,
+		Vars:  seq.sequenceConfig.Steps[0].Vars,
+		Req:   event.Data,
+		Event: eventInfo(event),
+	}
+	value, err := processor.Eval(ctx, seq.processorName(s.Processor), expression, p)
+	if err != nil {
+		l := logger(ctx)
+		l.Error().Err(err).Msg("Cannot evaluate throttle key of the sequence")
+		return ""
+	}
+	return correlationKey(value)
+}
@@ -0,0 +1,115 @@
+package sequencer
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/geliar/manopus/pkg/log"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequencer_RateLimit(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	current := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	SetClock(func() time.Time { return current })
+	defer SetClock(nil)
+	s := testSequencer(ctx, SequenceConfig{
+		Name: "deploy",
+		RateLimit: &RateLimitConfig{
+			Rate:    2,
+			Period:  60,
+			Key:     "req['user']",
+			Message: "slow down",
+		},
+		Steps: []StepConfig{{Script: "respond('deploying')"}},
+	})
+	event := func(user string) interface{} {
+		return s.Roll(ctx, testEvent(map[string]interface{}{"user": user}))
+	}
+	a.Equal("deploying", event("alice"))
+	a.Equal("deploying", event("alice"))
+	a.Equal("slow down", event("alice"))
+	//Buckets are separate for each key
+	a.Equal("deploying", event("bob"))
+	//One token is added in 30 seconds
+	current = current.Add(30 * time.Second)
+	a.Equal("deploying", event("alice"))
+	a.Equal("slow down", event("alice"))
+}
+
+func TestSequencer_Debounce(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	current := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	SetClock(func() time.Time { return current })
+	defer SetClock(nil)
+	s := testSequencer(ctx, SequenceConfig{
+		Name:     "build",
+		Single:   true,
+		Debounce: &DebounceConfig{Window: 10, Key: "req['repo']"},
+		Steps: []StepConfig{
+			{Name: "start", Script: "export['repo'] = req['repo']\nexport['commit'] = req['commit']"},
+			{Name: "wait", Match: "False"},
+		},
+	})
+	push := func(repo string, commit int) {
+		a.Nil(s.Roll(ctx, testEvent(map[string]interface{}{"repo": repo, "commit": commit})))
+	}
+	push("manopus", 1)
+	push("other", 1)
+	current = current.Add(5 * time.Second)
+	push("manopus", 2)
+	next, ok := s.nextDeadline(ctx)
+	a.True(ok)
+	a.Equal(current.Add(5*time.Second), next)
+
+	current = current.Add(6 * time.Second)
+	s.Sweep(ctx)
+	instances := s.Instances("build")
+	if a.Len(instances, 1) {
+		a.Equal("other", instances[0].Export["repo"])
+	}
+	//Window of the first push has been extended by the second one.
+	//Single sequence is running so the collapsed push is ignored
+	next, ok = s.nextDeadline(ctx)
+	a.True(ok)
+	a.Equal(current.Add(4*time.Second), next)
+	current = current.Add(5 * time.Second)
+	s.Sweep(ctx)
+	a.Len(s.Instances("build"), 1)
+	_, ok = s.nextDeadline(ctx)
+	a.False(ok)
+}
+
+func TestSequencer_DebounceCollapse(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	current := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	SetClock(func() time.Time { return current })
+	defer SetClock(nil)
+	s := testSequencer(ctx, SequenceConfig{
+		Name:     "build",
+		Debounce: &DebounceConfig{Window: 10, Message: "queued"},
+		Steps: []StepConfig{
+			{Name: "start", Script: "export['commit'] = req['commit']"},
+			{Name: "wait", Match: "False"},
+		},
+	})
+	for commit := 1; commit <= 3; commit++ {
+		a.Equal("queued", s.Roll(ctx, testEvent(map[string]interface{}{"commit": commit})))
+		current = current.Add(time.Second)
+	}
+	current = current.Add(10 * time.Second)
+	s.Sweep(ctx)
+	instances := s.Instances("build")
+	if a.Len(instances, 1) {
+		a.Equal(int64(3), instances[0].Export["commit"])
+	}
+}
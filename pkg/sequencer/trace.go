@@ -0,0 +1,43 @@
+package sequencer
+
+import (
+	"context"
+
+	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/trace"
+)
+
+// startSpan starts span of the sequence instance. The span joins the trace the instance has been started in,
+// so the steps run by later events, timeouts or after restart belong to the same trace.
+func (s *sequence) startSpan(ctx context.Context, name string) (context.Context, *trace.Span) {
+	var eventTrace string
+	if s.event != nil {
+		eventTrace = s.event.Trace
+	}
+	if s.trace == "" {
+		s.trace = eventTrace
+	}
+	if s.trace == "" {
+		s.trace = trace.Traceparent(ctx)
+	}
+	ctx, span := trace.Start(trace.WithRemote(ctx, s.trace), name)
+	span.SetAttr("sequence_name", s.sequenceConfig.Name)
+	span.SetAttr("sequence_id", s.id)
+	span.SetAttr("sequence_step", s.stepLabel())
+	if eventTrace != "" && eventTrace != s.trace {
+		span.SetAttr("event_traceparent", eventTrace)
+	}
+	if s.trace == "" {
+		s.trace = trace.Traceparent(ctx)
+	}
+	return ctx, span
+}
+
+// startMatchSpan starts span of matching the event with the sequence instance in the trace of the event
+func (s *sequence) startMatchSpan(ctx context.Context, event *payload.Event) (context.Context, *trace.Span) {
+	ctx, span := trace.Start(trace.WithRemote(ctx, event.Trace), "sequence.match")
+	span.SetAttr("sequence_name", s.sequenceConfig.Name)
+	span.SetAttr("sequence_id", s.id)
+	span.SetAttr("sequence_step", s.stepLabel())
+	return ctx, span
+}
@@ -0,0 +1,85 @@
+package sequencer
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/trace"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequencer_Trace(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	file := filepath.Join(t.TempDir(), "spans.jsonl")
+	trace.Init(ctx, trace.Config{Exporter: "file", File: file})
+	defer trace.Stop(ctx)
+	s := testSequencer(ctx, SequenceConfig{
+		Name: "traced",
+		Steps: []StepConfig{
+			{Name: "start", Match: "req['cmd'] == 'trace'"},
+			{Name: "answer", Match: "req['answer']", Script: "stop()"},
+		},
+	})
+	defer s.Stop(ctx)
+	first := "00-11111111111111111111111111111111-1111111111111111-01"
+	second := "00-22222222222222222222222222222222-2222222222222222-01"
+	event := testEvent(map[string]interface{}{"cmd": "trace"})
+	event.Trace = first
+	a.Nil(s.Roll(ctx, event))
+	instances := s.Instances("traced")
+	if !a.Len(instances, 1) {
+		return
+	}
+
+	//Trace is stored with the persisted instance
+	seq := s.queue.Pop(instances[0].ID)
+	buf, err := json.Marshal(seq)
+	a.NoError(err)
+	restored := new(sequence)
+	a.NoError(json.Unmarshal(buf, restored))
+	a.Equal(seq.trace, restored.trace)
+	a.True(strings.HasPrefix(restored.trace, "00-11111111111111111111111111111111-"))
+	s.queue.Push(restored)
+
+	event = testEvent(map[string]interface{}{"answer": true})
+	event.Trace = second
+	a.Nil(s.Roll(ctx, event))
+	trace.Stop(ctx)
+
+	content, err := ioutil.ReadFile(file)
+	a.NoError(err)
+	var runs, matches []trace.SpanData
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var span trace.SpanData
+		a.NoError(json.Unmarshal([]byte(line), &span))
+		switch span.Name {
+		case "sequence.run":
+			runs = append(runs, span)
+		case "sequence.match":
+			if span.Attributes["matched"] == "true" {
+				matches = append(matches, span)
+			}
+		}
+	}
+	if !a.Len(runs, 2) || !a.Len(matches, 2) {
+		return
+	}
+	//Both steps belong to the trace the instance has been started in
+	a.Equal("11111111111111111111111111111111", runs[0].TraceID)
+	a.Equal("11111111111111111111111111111111", runs[1].TraceID)
+	a.Equal("start", runs[0].Attributes["sequence_step"])
+	a.Equal("answer", runs[1].Attributes["sequence_step"])
+	a.Equal(second, runs[1].Attributes["event_traceparent"])
+	//Matching belongs to the trace of the event
+	a.Equal("11111111111111111111111111111111", matches[0].TraceID)
+	a.Equal("22222222222222222222222222222222", matches[1].TraceID)
+	a.Equal("2222222222222222", matches[1].ParentID)
+}
@@ -0,0 +1,67 @@
+package sequencer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/geliar/manopus/pkg/payload"
+)
+
+type collectorKey struct{}
+
+// collector collects responses of the sequences processed within the context
+type collector struct {
+	responses []payload.Response
+	sync.Mutex
+}
+
+// TriggerResult result of the manually triggered event
+type TriggerResult struct {
+	//Callback callback data returned by the sequences
+	Callback interface{} `json:"callback"`
+	//Responses responses sent by the sequences to outputs
+	Responses []payload.Response `json:"responses"`
+}
+
+// Trigger process synthetic event with sequences as it was received from the input.
+// Event is matched only with sequences with specified name if name is not empty.
+func (s *Sequencer) Trigger(ctx context.Context, event *payload.Event, name string) (result TriggerResult, err error) {
+	l := logger(ctx)
+	if event.Input == "" {
+		return result, errors.New("input of the event should be specified")
+	}
+	if name != "" {
+		s.RLock()
+		_, ok := s.sequenceConfig(name)
+		s.RUnlock()
+		if !ok {
+			return result, fmt.Errorf("sequence '%s' %w", name, errNotFound)
+		}
+	}
+	if event.ID == "" {
+		event.ID = "trigger-" + s.newID()
+	}
+	l.Info().
+		Str("event_input", event.Input).
+		Str("event_type", event.Type).
+		Str("event_id", event.ID).
+		Str("sequence_name", name).
+		Msg("Triggering event manually")
+	c := new(collector)
+	result.Callback = s.roll(context.WithValue(ctx, collectorKey{}, c), event, name)
+	result.Responses = c.responses
+	return result, nil
+}
+
+// collect adds response to the collector of the context if there is one
+func collect(ctx context.Context, response payload.Response) {
+	c, ok := ctx.Value(collectorKey{}).(*collector)
+	if !ok {
+		return
+	}
+	c.Lock()
+	c.responses = append(c.responses, response)
+	c.Unlock()
+}
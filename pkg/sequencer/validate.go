@@ -0,0 +1,174 @@
+package sequencer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Validate checks configuration of the sequencer and its sequences
+func (s *Sequencer) Validate() error {
+	if !validReconcile(s.Reconcile) {
+		return fmt.Errorf("unknown reconcile strategy '%s'", s.Reconcile)
+	}
+	names := make(map[string]struct{})
+	for i := range s.SequenceConfigs {
+		sc := &s.SequenceConfigs[i]
+		if sc.Name != "" {
+			if _, ok := names[sc.Name]; ok {
+				return fmt.Errorf("sequence name '%s' is not unique", sc.Name)
+			}
+			names[sc.Name] = struct{}{}
+		}
+		if err := s.validateSequence(sc); err != nil {
+			if sc.Name == "" {
+				return fmt.Errorf("sequence #%d: %s", i, err)
+			}
+			return fmt.Errorf("sequence '%s': %s", sc.Name, err)
+		}
+	}
+	if s.Fallback != "" {
+		if _, ok := names[s.Fallback]; !ok {
+			return fmt.Errorf("fallback sequence '%s' does not exist", s.Fallback)
+		}
+	}
+	return s.validateCalls()
+}
+
+// validateCalls checks that sequences do not call themselves directly or through other sequences
+func (s *Sequencer) validateCalls() error {
+	calls := make(map[string][]string)
+	for i := range s.SequenceConfigs {
+		sc := &s.SequenceConfigs[i]
+		for j := range sc.Steps {
+			if call := sc.Steps[j].Call; call != nil {
+				calls[sc.Name] = append(calls[sc.Name], call.Sequence)
+			}
+		}
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		path = append(path, name)
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("sequences call each other in a cycle: %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, called := range calls[name] {
+			if err := visit(called, path); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for i := range s.SequenceConfigs {
+		if err := visit(s.SequenceConfigs[i].Name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Sequencer) validateSequence(sc *SequenceConfig) error {
+	if len(sc.Steps) == 0 {
+		return fmt.Errorf("sequence has no steps")
+	}
+	switch sc.LimitPolicy {
+	case "", LimitPolicyReject, LimitPolicyEvict, LimitPolicyQueue:
+	default:
+		return fmt.Errorf("unknown limit policy '%s'", sc.LimitPolicy)
+	}
+	if !validReconcile(sc.Reconcile) {
+		return fmt.Errorf("unknown reconcile strategy '%s'", sc.Reconcile)
+	}
+	if sc.RateLimit != nil && sc.RateLimit.Rate <= 0 {
+		return fmt.Errorf("rate_limit: rate should be positive")
+	}
+	if sc.Debounce != nil && sc.Debounce.Window <= 0 {
+		return fmt.Errorf("debounce: window should be positive")
+	}
+	steps := make(map[string]struct{})
+	for i := range sc.Steps {
+		if name := sc.Steps[i].Name; name != "" {
+			if _, ok := steps[name]; ok {
+				return fmt.Errorf("step name '%s' is not unique", name)
+			}
+			steps[name] = struct{}{}
+		}
+	}
+	if err := validateHandler(sc.OnTimeout, steps); err != nil {
+		return fmt.Errorf("on_timeout: %s", err)
+	}
+	for i := range sc.Steps {
+		step := &sc.Steps[i]
+		if i == 0 && step.Wait != nil {
+			return fmt.Errorf("first step cannot wait, sequence is started by the event")
+		}
+		if err := s.validateStep(step, steps); err != nil {
+			if step.Name == "" {
+				return fmt.Errorf("step #%d: %s", i, err)
+			}
+			return fmt.Errorf("step '%s': %s", step.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *Sequencer) validateStep(step *StepConfig, steps map[string]struct{}) error {
+	if err := validateHandler(step.OnTimeout, steps); err != nil {
+		return fmt.Errorf("on_timeout: %s", err)
+	}
+	if err := validateHandler(step.OnError, steps); err != nil {
+		return fmt.Errorf("on_error: %s", err)
+	}
+	if step.Call != nil {
+		if _, ok := s.sequenceConfig(step.Call.Sequence); !ok {
+			return fmt.Errorf("cannot find sequence '%s' to call", step.Call.Sequence)
+		}
+	}
+	if step.Wait != nil {
+		if step.Wait.Duration < 0 {
+			return fmt.Errorf("wait duration cannot be negative")
+		}
+		if step.Call != nil || len(step.Branches) > 0 || step.Correlation != nil {
+			return fmt.Errorf("wait step cannot have call, branches or correlation")
+		}
+	}
+	if step.Retry != nil {
+		if step.Retry.Attempts < 0 || step.Retry.Backoff < 0 {
+			return fmt.Errorf("retry attempts and backoff cannot be negative")
+		}
+		for _, e := range step.Retry.Errors {
+			if _, err := regexp.Compile(e); err != nil {
+				return fmt.Errorf("retry errors: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateHandler(h *HandlerConfig, steps map[string]struct{}) error {
+	if h == nil || h.Step == "" {
+		return nil
+	}
+	if _, ok := steps[h.Step]; !ok {
+		return fmt.Errorf("cannot find step '%s'", h.Step)
+	}
+	return nil
+}
+
+func validReconcile(strategy string) bool {
+	switch strategy {
+	case "", ReconcileMigrate, ReconcileKeep, ReconcileDrop:
+		return true
+	}
+	return false
+}
@@ -52,6 +52,20 @@ func (s *BoltDB) Load(ctx context.Context, key string) (value []byte, err error)
 	return value, err
 }
 
+// Delete removes value from the store
+func (s *BoltDB) Delete(ctx context.Context, key string) (err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(s.bucket))
+		if b == nil {
+			l := logger(ctx)
+			l.Error().Str("bucket", s.bucket).Msg("Cannot get BoltDB bucket")
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+	return
+}
+
 // Stop stops store
 func (s *BoltDB) Stop(ctx context.Context) {
 	_ = s.db.Close()
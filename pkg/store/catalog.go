@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"sync"
+	"time"
 )
 
 // Config configuration structure for store
@@ -49,6 +50,11 @@ func Load(ctx context.Context, name string, key string) (value []byte, err error
 	return stores.load(ctx, name, key)
 }
 
+// Delete removes data from specified store. Empty value is saved if the store cannot remove values
+func Delete(ctx context.Context, name string, key string) (err error) {
+	return stores.delete(ctx, name, key)
+}
+
 // ConfigureStore configures store with configuration data
 func ConfigureStore(ctx context.Context, name string, store Config) {
 	builders.configure(ctx, name, store)
@@ -59,6 +65,22 @@ func StopAll(ctx context.Context) {
 	stores.stopAll(ctx)
 }
 
+// BuilderExists checks if store builder with specified type is registered in the catalog
+func BuilderExists(storeType string) bool {
+	builders.RLock()
+	defer builders.RUnlock()
+	_, ok := builders.builders[storeType]
+	return ok
+}
+
+// Exists checks if store with specified name is configured
+func Exists(name string) bool {
+	stores.RLock()
+	defer stores.RUnlock()
+	_, ok := stores.stores[name]
+	return ok
+}
+
 func (c *catalogStores) register(ctx context.Context, store Store) {
 	c.Lock()
 	defer c.Unlock()
@@ -93,7 +115,14 @@ func (c *catalogStores) save(ctx context.Context, name string, key string, value
 	}
 	p := c.stores[name]
 	c.RUnlock()
-	return p.Save(ctx, key, value)
+	start := time.Now()
+	err = p.Save(ctx, key, value)
+	metricSaveDuration.Since(start, name)
+	metricSaveSize.Observe(float64(len(value)), name)
+	if err != nil {
+		metricErrors.Inc(name, "save")
+	}
+	return err
 }
 
 func (c *catalogStores) load(ctx context.Context, name string, key string) (value []byte, err error) {
@@ -109,7 +138,35 @@ func (c *catalogStores) load(ctx context.Context, name string, key string) (valu
 	}
 	p := c.stores[name]
 	c.RUnlock()
-	return p.Load(ctx, key)
+	value, err = p.Load(ctx, key)
+	if err != nil {
+		metricErrors.Inc(name, "load")
+	}
+	return value, err
+}
+
+func (c *catalogStores) delete(ctx context.Context, name string, key string) (err error) {
+	c.RLock()
+
+	l := logger(ctx)
+	if _, ok := c.stores[name]; !ok {
+		c.RUnlock()
+		l.Error().
+			Str("store_name", name).
+			Msgf("Cannot find store with name '%s'", name)
+		return
+	}
+	p := c.stores[name]
+	c.RUnlock()
+	if d, ok := p.(Deleter); ok {
+		err = d.Delete(ctx, key)
+	} else {
+		err = p.Save(ctx, key, nil)
+	}
+	if err != nil {
+		metricErrors.Inc(name, "delete")
+	}
+	return err
 }
 
 func (c *catalogStores) stopAll(ctx context.Context) {
@@ -0,0 +1,27 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/geliar/manopus/pkg/log"
+	"github.com/geliar/manopus/pkg/store"
+)
+
+func init() {
+	store.RegisterBuilder(log.Logger.WithContext(context.Background()), serviceName, builder)
+}
+
+func builder(ctx context.Context, name string, config map[string]interface{}) {
+	l := logger(ctx)
+	l = l.With().
+		Str("store_name", name).
+		Str("store_type", serviceName).
+		Logger()
+	ctx = l.WithContext(ctx)
+	l.Debug().Msgf("Initializing new instance of %s", name)
+
+	i := new(Memory)
+	i.name = name
+	i.values = make(map[string][]byte)
+	store.RegisterStore(ctx, i)
+}
@@ -0,0 +1,21 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/geliar/manopus/pkg/log"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	serviceName = "memory"
+	serviceType = "store"
+)
+
+func logger(ctx context.Context) zerolog.Logger {
+	return log.Ctx(ctx).With().
+		Str("service", serviceName).
+		Str("service_type", serviceType).
+		Logger()
+}
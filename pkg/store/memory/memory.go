@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory store implementation which keeps values in memory.
+// Values are lost on restart so it is useful for tests and stateless setups.
+type Memory struct {
+	name   string
+	values map[string][]byte
+	sync.RWMutex
+}
+
+// Name returns name of the store
+func (s *Memory) Name() string {
+	return s.name
+}
+
+// Type returns type of store
+func (s *Memory) Type() string {
+	return serviceName
+}
+
+// Save puts data to specified store
+func (s *Memory) Save(ctx context.Context, key string, value []byte) (err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.values[key] = append([]byte(nil), value...)
+	return nil
+}
+
+// Load returns data from the store
+func (s *Memory) Load(ctx context.Context, key string) (value []byte, err error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.values[key], nil
+}
+
+// Delete removes value from the store
+func (s *Memory) Delete(ctx context.Context, key string) (err error) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.values, key)
+	return nil
+}
+
+// Stop stops store
+func (s *Memory) Stop(ctx context.Context) {
+	s.Lock()
+	defer s.Unlock()
+	s.values = make(map[string][]byte)
+}
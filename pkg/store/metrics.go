@@ -0,0 +1,15 @@
+package store
+
+import (
+	"github.com/geliar/manopus/pkg/metrics"
+)
+
+var (
+	metricSaveDuration = metrics.NewHistogram("manopus_store_save_duration_seconds",
+		"Time spent saving values to the store.", nil, "store")
+	metricSaveSize = metrics.NewHistogram("manopus_store_save_bytes",
+		"Size of the values saved to the store.",
+		[]float64{256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216}, "store")
+	metricErrors = metrics.NewCounter("manopus_store_errors_total",
+		"Number of failed store operations.", "store", "operation")
+)
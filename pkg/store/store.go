@@ -15,3 +15,9 @@ type Store interface {
 	//Stop stops store instance
 	Stop(ctx context.Context)
 }
+
+// Deleter optional interface of the Store which can remove values
+type Deleter interface {
+	//Delete removes value with provided key. Removing of missing key is not an error
+	Delete(ctx context.Context, key string) (err error)
+}
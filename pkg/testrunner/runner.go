@@ -0,0 +1,281 @@
+package testrunner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/geliar/manopus/pkg/config"
+	"github.com/geliar/manopus/pkg/input"
+	"github.com/geliar/manopus/pkg/output"
+	"github.com/geliar/manopus/pkg/payload"
+	"github.com/geliar/manopus/pkg/report"
+	"github.com/geliar/manopus/pkg/sequencer"
+	"github.com/geliar/manopus/pkg/store"
+
+	//Store which keeps the state of the tested sequencers
+	_ "github.com/geliar/manopus/pkg/store/memory"
+)
+
+// storeName name of the in-memory store of the tested sequencers
+const storeName = "testrunner"
+
+var configureStore sync.Once
+
+// Result result of the test
+type Result struct {
+	//Suite path to the test file
+	Suite string
+	//Name name of the test
+	Name     string
+	Duration time.Duration
+	//Failures messages of the failed assertions
+	Failures []string
+}
+
+// Passed checks if all assertions of the test passed
+func (r *Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// Run runs tests of the suite with the sequences of its configuration.
+// Connectors of the configuration are replaced with in-memory stand-ins.
+func Run(ctx context.Context, suite *Suite) ([]Result, error) {
+	l := logger(ctx).With().Str("test_suite", suite.path).Logger()
+	ctx = l.WithContext(ctx)
+	c, err := config.Load(ctx, suite.Config)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	var results []Result
+	for i := range suite.Tests {
+		results = append(results, runTest(ctx, c, suite, i))
+	}
+	return results, nil
+}
+
+func runTest(ctx context.Context, c *config.Config, suite *Suite, index int) (result Result) {
+	t := &suite.Tests[index]
+	started := time.Now()
+	result = Result{Suite: suite.path, Name: t.Name}
+	if result.Name == "" {
+		result.Name = fmt.Sprintf("test #%d", index+1)
+	}
+	l := logger(ctx).With().Str("test_name", result.Name).Logger()
+	ctx = l.WithContext(ctx)
+	defer func() { result.Duration = time.Since(started) }()
+	fail := func(format string, args ...interface{}) {
+		result.Failures = append(result.Failures, fmt.Sprintf(format, args...))
+	}
+
+	clock := &fakeClock{now: time.Now().UTC()}
+	if t.Clock != "" {
+		now, err := time.Parse(time.RFC3339, t.Clock)
+		if err != nil {
+			fail("cannot parse clock: %s", err)
+			return
+		}
+		clock.now = now
+	}
+	sequencer.SetClock(clock.Now)
+	defer sequencer.SetClock(nil)
+	report.Init(ctx, report.Config{Driver: report.DiscardDriver})
+	configureStore.Do(func() {
+		store.ConfigureStore(ctx, storeName, store.Config{Type: "memory"})
+	})
+
+	captured := new(capture)
+	inputs := make(map[string]*stubInput)
+	for name, cn := range c.Connectors {
+		in := &stubInput{name: name, connectorType: cn.Type}
+		input.Register(ctx, name, in)
+		defer input.Unregister(ctx, name)
+		output.Register(ctx, name, &stubOutput{name: name, connectorType: cn.Type, calls: t.Calls, captured: captured})
+		defer output.Unregister(ctx, name)
+		inputs[name] = in
+	}
+	s := &sequencer.Sequencer{
+		Env:             c.Sequencer.Env,
+		Inputs:          c.Sequencer.Inputs,
+		Processor:       c.Sequencer.Processor,
+		Reconcile:       c.Sequencer.Reconcile,
+		Fallback:        c.Sequencer.Fallback,
+		SequenceConfigs: c.Sequencer.SequenceConfigs,
+		Store:           storeName,
+		StoreKey:        fmt.Sprintf("%s#%d", suite.path, index),
+	}
+	s.Init(ctx, true)
+	defer s.Stop(ctx)
+	for _, in := range inputs {
+		in.RegisterHandler(ctx, s.Roll)
+	}
+
+	for i, step := range t.Steps {
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("step #%d", i+1)
+		}
+		if step.Advance > 0 {
+			clock.Advance(time.Duration(step.Advance) * time.Second)
+			s.Sweep(ctx)
+		}
+		var callback interface{}
+		if e := step.Event; e != nil {
+			in, ok := inputs[e.Input]
+			if !ok {
+				fail("%s: unknown input '%s'", name, e.Input)
+				continue
+			}
+			event, err := e.event(in.connectorType, fmt.Sprintf("test-%d", i+1))
+			if err != nil {
+				fail("%s: cannot decode event data: %s", name, err)
+				continue
+			}
+			callback = in.send(ctx, event)
+		}
+		responses := captured.take()
+		if step.Callback != nil && !subset(step.Callback, callback) {
+			fail("%s: expected callback %s, got %s", name, jsonString(step.Callback), jsonString(callback))
+		}
+		if step.Responses != nil {
+			checkResponses(name, *step.Responses, responses, fail)
+		}
+	}
+	if t.State != nil {
+		checkState(*t.State, s.Instances(""), fail)
+	}
+	return
+}
+
+// event makes event of the test with data decoded to the data type of the connector
+func (e *Event) event(connectorType string, id string) (*payload.Event, error) {
+	data := e.Data
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := payload.DecodeData(connectorType, e.Type, buf)
+	if err != nil {
+		return nil, err
+	}
+	if e.ID != "" {
+		id = e.ID
+	}
+	return &payload.Event{Input: e.Input, Type: e.Type, ID: id, Data: decoded}, nil
+}
+
+func checkResponses(step string, expected []Response, responses []payload.Response, fail func(format string, args ...interface{})) {
+	if len(expected) != len(responses) {
+		fail("%s: expected %d response(s), got %d: %s", step, len(expected), len(responses), jsonString(responseData(responses)))
+		return
+	}
+	for i, e := range expected {
+		r := responses[i]
+		if e.Output != r.Output || (e.Data != nil && !subset(e.Data, r.Data)) {
+			fail("%s: response #%d: expected %s, got %s", step, i+1,
+				jsonString(Response{Output: e.Output, Data: e.Data}), jsonString(responseData(responses[i : i+1])[0]))
+		}
+	}
+}
+
+func checkState(expected []Instance, instances []sequencer.Instance, fail func(format string, args ...interface{})) {
+	var actual []Instance
+	for _, i := range instances {
+		actual = append(actual, Instance{Sequence: i.Name, Step: i.StepName, Export: i.Export})
+	}
+	if len(expected) != len(actual) {
+		fail("state: expected %d instance(s), got %d: %s", len(expected), len(actual), jsonString(actual))
+		return
+	}
+	used := make([]bool, len(actual))
+	for _, e := range expected {
+		found := false
+		for i, a := range actual {
+			if used[i] || e.Sequence != a.Sequence || (e.Step != "" && e.Step != a.Step) ||
+				(e.Export != nil && !subset(e.Export, a.Export)) {
+				continue
+			}
+			used[i] = true
+			found = true
+			break
+		}
+		if !found {
+			fail("state: cannot find instance %s in %s", jsonString(e), jsonString(actual))
+		}
+	}
+}
+
+// subset checks if expected value is a subset of actual value.
+// Maps are compared by the keys of expected map, other values should be equal.
+// Values are compared in their JSON representation.
+func subset(expected, actual interface{}) bool {
+	return subsetJSON(normalize(expected), normalize(actual))
+}
+
+func subsetJSON(expected, actual interface{}) bool {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range e {
+			if av, ok := a[k]; !ok || !subsetJSON(v, av) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for i := range e {
+			if !subsetJSON(e[i], a[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(expected, actual)
+}
+
+func normalize(v interface{}) interface{} {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var n interface{}
+	if err := json.Unmarshal(buf, &n); err != nil {
+		return v
+	}
+	return n
+}
+
+func responseData(responses []payload.Response) (r []Response) {
+	for _, resp := range responses {
+		r = append(r, Response{Output: resp.Output, Data: resp.Data})
+	}
+	return
+}
+
+func jsonString(v interface{}) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return strings.TrimSpace(buf.String())
+}
@@ -0,0 +1,77 @@
+package testrunner
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/geliar/manopus/pkg/log"
+
+	//Connectors
+	_ "github.com/geliar/manopus/pkg/connector/bitbucket"
+	_ "github.com/geliar/manopus/pkg/connector/github"
+	_ "github.com/geliar/manopus/pkg/connector/http"
+	_ "github.com/geliar/manopus/pkg/connector/slack"
+	_ "github.com/geliar/manopus/pkg/connector/timer"
+
+	//Processors
+	_ "github.com/geliar/manopus/pkg/processor/starlark"
+
+	//Stores
+	_ "github.com/geliar/manopus/pkg/store/boltdb"
+
+	//Reporters
+	_ "github.com/geliar/manopus/pkg/report/fs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun_Examples(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	suite, err := LoadSuite("../../examples/tests/test.yaml")
+	a.NoError(err)
+	results, err := Run(ctx, suite)
+	a.NoError(err)
+	a.Len(results, len(suite.Tests))
+	for _, r := range results {
+		a.True(r.Passed(), "%s: %v", r.Name, r.Failures)
+	}
+}
+
+func TestRun_Failures(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	suite := &Suite{
+		Config: []string{"../../examples/test"},
+		path:   "greeting.yaml",
+		Tests: []Test{
+			{
+				Name: "wrong expectations",
+				Steps: []Step{
+					{
+						Event:     &Event{Input: "slack", Type: "event", Data: map[string]interface{}{"user_id": "U1", "message": "hi", "mentioned": true}},
+						Callback:  "Hello",
+						Responses: &[]Response{{Output: "slack"}},
+					},
+					{Event: &Event{Input: "unknown"}},
+				},
+				State: &[]Instance{{Sequence: "greating sequence", Step: "ask for name"}},
+			},
+		},
+	}
+	results, err := Run(ctx, suite)
+	a.NoError(err)
+	a.Len(results, 1)
+	a.False(results[0].Passed())
+	a.Len(results[0].Failures, 4)
+
+	var buf bytes.Buffer
+	a.NoError(WriteJUnit(&buf, results))
+	a.Contains(buf.String(), `<testsuite name="greeting.yaml" tests="1" failures="1"`)
+	a.Contains(buf.String(), `<testcase name="wrong expectations" classname="greeting"`)
+	a.Contains(buf.String(), `<failure message="step #1: expected callback &#34;Hello&#34;, got &#34;Hi &lt;@U1&gt;, what is your name?&#34;">`)
+}
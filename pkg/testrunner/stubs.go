@@ -0,0 +1,100 @@
+package testrunner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/geliar/manopus/pkg/input"
+	"github.com/geliar/manopus/pkg/payload"
+)
+
+// stubInput in-memory stand-in of the connector input which passes events of the test to the handler
+type stubInput struct {
+	name          string
+	connectorType string
+	handler       input.Handler
+	sync.RWMutex
+}
+
+func (i *stubInput) Name() string             { return i.name }
+func (i *stubInput) Type() string             { return i.connectorType }
+func (i *stubInput) Stop(ctx context.Context) {}
+
+func (i *stubInput) RegisterHandler(ctx context.Context, handler input.Handler) {
+	i.Lock()
+	defer i.Unlock()
+	i.handler = handler
+}
+
+func (i *stubInput) send(ctx context.Context, event *payload.Event) interface{} {
+	i.RLock()
+	handler := i.handler
+	i.RUnlock()
+	if handler == nil {
+		return nil
+	}
+	return handler(ctx, event)
+}
+
+// stubOutput in-memory stand-in of the connector output which captures responses
+// and returns canned results of the test
+type stubOutput struct {
+	name          string
+	connectorType string
+	calls         []Call
+	captured      *capture
+}
+
+func (o *stubOutput) Name() string             { return o.name }
+func (o *stubOutput) Type() string             { return o.connectorType }
+func (o *stubOutput) Stop(ctx context.Context) {}
+
+func (o *stubOutput) Send(ctx context.Context, response *payload.Response) map[string]interface{} {
+	o.captured.add(*response)
+	for _, c := range o.calls {
+		if c.Output == o.name && (c.Match == nil || subset(c.Match, response.Data)) {
+			return c.Result
+		}
+	}
+	return nil
+}
+
+// capture keeps responses sent to the stub outputs
+type capture struct {
+	responses []payload.Response
+	sync.Mutex
+}
+
+func (c *capture) add(response payload.Response) {
+	c.Lock()
+	defer c.Unlock()
+	c.responses = append(c.responses, response)
+}
+
+// take returns captured responses and resets the capture
+func (c *capture) take() []payload.Response {
+	c.Lock()
+	defer c.Unlock()
+	responses := c.responses
+	c.responses = nil
+	return responses
+}
+
+// fakeClock clock of the sequences which is advanced by the test
+type fakeClock struct {
+	now time.Time
+	sync.Mutex
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,106 @@
+package testrunner
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/geliar/yaml"
+)
+
+// Suite describes test file with tests of the sequences
+type Suite struct {
+	//Config the list of config files or dirs relative to the test file
+	Config []string `yaml:"config"`
+	//Tests the list of tests
+	Tests []Test `yaml:"tests"`
+	//path path to the test file
+	path string
+}
+
+// Test describes test of the sequences
+type Test struct {
+	//Name name of the test
+	Name string `yaml:"name"`
+	//Clock (optional) RFC3339 time the fake clock starts with. Current time by default
+	Clock string `yaml:"clock"`
+	//Calls (optional) canned results of the responses sent to outputs
+	Calls []Call `yaml:"calls"`
+	//Steps the list of steps of the test
+	Steps []Step `yaml:"steps"`
+	//State (optional) expected running instances of the sequences after the last step
+	State *[]Instance `yaml:"state"`
+}
+
+// Call describes canned result of the response sent to output.
+// The first call which output and match fit the response is used.
+type Call struct {
+	//Output name of the output
+	Output string `yaml:"output"`
+	//Match (optional) data which should be a subset of the response data
+	Match map[string]interface{} `yaml:"match"`
+	//Result result returned by the output
+	Result map[string]interface{} `yaml:"result"`
+}
+
+// Step describes step of the test. Clock is advanced before the event is sent.
+type Step struct {
+	//Name (optional) name of the step for the failure messages
+	Name string `yaml:"name"`
+	//Advance (optional) number of seconds to advance the fake clock by. Timed out sequences are processed
+	Advance int64 `yaml:"advance"`
+	//Event (optional) event to send to the sequences
+	Event *Event `yaml:"event"`
+	//Callback (optional) expected callback of the event
+	Callback interface{} `yaml:"callback"`
+	//Responses (optional) expected responses sent to outputs during the step
+	Responses *[]Response `yaml:"responses"`
+}
+
+// Event describes input event of the test
+type Event struct {
+	//Input name of the input (connector) the event comes from
+	Input string `yaml:"input"`
+	//Type type of the event
+	Type string `yaml:"type"`
+	//ID (optional) ID of the event
+	ID string `yaml:"id"`
+	//Data data of the event. It is decoded to the data type of the connector
+	Data interface{} `yaml:"data"`
+}
+
+// Response describes expected response sent to output
+type Response struct {
+	//Output name of the output
+	Output string `yaml:"output" json:"output"`
+	//Data (optional) data which should be a subset of the response data
+	Data map[string]interface{} `yaml:"data" json:"data,omitempty"`
+}
+
+// Instance describes expected running instance of the sequence
+type Instance struct {
+	//Sequence name of the sequence
+	Sequence string `yaml:"sequence" json:"sequence"`
+	//Step (optional) name of the current step
+	Step string `yaml:"step" json:"step,omitempty"`
+	//Export (optional) data which should be a subset of the exports of the instance
+	Export map[string]interface{} `yaml:"export" json:"export,omitempty"`
+}
+
+// LoadSuite reads test file
+func LoadSuite(path string) (*Suite, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Suite
+	if err := yaml.Unmarshal(buf, &s); err != nil {
+		return nil, err
+	}
+	s.path = path
+	for i := range s.Config {
+		if !filepath.IsAbs(s.Config[i]) {
+			s.Config[i] = filepath.Join(filepath.Dir(path), s.Config[i])
+		}
+	}
+	return &s, nil
+}
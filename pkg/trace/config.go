@@ -0,0 +1,19 @@
+package trace
+
+// Config config structure of the tracing of event processing
+type Config struct {
+	//Exporter type of the exporter of the spans: file or otlp. Tracing is disabled if empty
+	Exporter string `yaml:"exporter"`
+	//File path to JSON Lines file the spans are appended to (file exporter)
+	File string `yaml:"file"`
+	//Endpoint URL of OTLP/HTTP JSON traces endpoint of the collector, e.g. http://localhost:4318/v1/traces (otlp exporter)
+	Endpoint string `yaml:"endpoint"`
+	//Headers (optional) additional headers of the requests to the collector
+	Headers map[string]string `yaml:"headers"`
+	//ServiceName (optional) name of the service reported with the spans. Default is manopus
+	ServiceName string `yaml:"service_name"`
+	//BatchSize (optional) maximum number of spans exported at once. Default is 512
+	BatchSize int `yaml:"batch_size"`
+	//Interval (optional) interval in seconds between exports of the spans. Default is 5
+	Interval int64 `yaml:"interval"`
+}
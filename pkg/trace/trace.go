@@ -0,0 +1,177 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header name of HTTP header carrying W3C trace context
+const Header = "traceparent"
+
+var errTraceparent = errors.New("invalid traceparent")
+
+// SpanContext identifies the span and the trace it belongs to
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// Valid checks that trace and span IDs are set
+func (c SpanContext) Valid() bool {
+	return c.TraceID != [16]byte{} && c.SpanID != [8]byte{}
+}
+
+// String returns span context in W3C traceparent format
+func (c SpanContext) String() string {
+	if !c.Valid() {
+		return ""
+	}
+	return "00-" + hex.EncodeToString(c.TraceID[:]) + "-" + hex.EncodeToString(c.SpanID[:]) + "-" + hex.EncodeToString([]byte{c.Flags})
+}
+
+// Parse parses span context from W3C traceparent value
+func Parse(traceparent string) (SpanContext, error) {
+	var c SpanContext
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return c, errTraceparent
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return c, errTraceparent
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(c.TraceID[:], []byte(parts[1])); err != nil {
+		return c, errTraceparent
+	}
+	if _, err := hex.Decode(c.SpanID[:], []byte(parts[2])); err != nil {
+		return c, errTraceparent
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return c, errTraceparent
+	}
+	c.Flags = flags[0]
+	if !c.Valid() {
+		return c, errTraceparent
+	}
+	return c, nil
+}
+
+type contextKey struct{}
+
+// fromContext returns span context stored in the context
+func fromContext(ctx context.Context) (SpanContext, bool) {
+	c, ok := ctx.Value(contextKey{}).(SpanContext)
+	return c, ok
+}
+
+// WithRemote returns context which new spans are children of the span from traceparent.
+// Returns ctx as is if traceparent is empty or invalid.
+func WithRemote(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	c, err := Parse(traceparent)
+	if err != nil {
+		l := logger(ctx)
+		l.Debug().Str("traceparent", traceparent).Msg("Ignoring invalid traceparent")
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// Traceparent returns W3C traceparent of the current span of the context or empty string
+func Traceparent(ctx context.Context) string {
+	c, _ := fromContext(ctx)
+	return c.String()
+}
+
+// FromRequest returns W3C traceparent of the current span of the request context
+// or the traceparent header of the request
+func FromRequest(r *http.Request) string {
+	if tp := Traceparent(r.Context()); tp != "" {
+		return tp
+	}
+	if _, err := Parse(r.Header.Get(Header)); err != nil {
+		return ""
+	}
+	return r.Header.Get(Header)
+}
+
+// Span the timed operation of the trace. Methods of nil Span do nothing, so callers do not check if tracing is enabled.
+type Span struct {
+	tracer *tracer
+	data   SpanData
+	ended  bool
+	sync.Mutex
+}
+
+// Start starts new span which is a child of the current span of the context or the root of new trace.
+// Returns the context with the new span and the span. The span is nil if tracing is disabled.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	t := global.active()
+	if t == nil {
+		return ctx, nil
+	}
+	c, ok := fromContext(ctx)
+	s := &Span{tracer: t, data: SpanData{Name: name, Start: now()}}
+	if ok {
+		s.data.ParentID = hex.EncodeToString(c.SpanID[:])
+	} else {
+		c.Flags = 1
+		_, _ = rand.Read(c.TraceID[:])
+	}
+	_, _ = rand.Read(c.SpanID[:])
+	s.data.TraceID = hex.EncodeToString(c.TraceID[:])
+	s.data.SpanID = hex.EncodeToString(c.SpanID[:])
+	return context.WithValue(ctx, contextKey{}, c), s
+}
+
+// SetAttr sets attribute of the span
+func (s *Span) SetAttr(key, value string) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]string)
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetError marks the span as failed. Does nothing if err is nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	if s.ended {
+		s.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = now()
+	data := s.data
+	s.Unlock()
+	s.tracer.enqueue(data)
+}
+
+var now = time.Now
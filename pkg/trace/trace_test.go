@@ -0,0 +1,155 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/geliar/manopus/pkg/log"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	a := assert.New(t)
+	tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	c, err := Parse(tp)
+	a.NoError(err)
+	a.Equal(byte(1), c.Flags)
+	a.Equal(tp, c.String())
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		_, err := Parse(invalid)
+		a.Error(err, invalid)
+	}
+}
+
+func TestTrace_Disabled(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	Stop(ctx)
+	tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx = WithRemote(ctx, tp)
+	ctx, span := Start(ctx, "disabled")
+	a.Nil(span)
+	span.SetAttr("key", "value")
+	span.SetError(errors.New("error"))
+	span.End()
+	a.Equal(tp, Traceparent(ctx))
+}
+
+func TestTrace_File(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	file := filepath.Join(t.TempDir(), "spans.jsonl")
+	Init(ctx, Config{Exporter: "file", File: file})
+	defer Stop(ctx)
+
+	ctx = WithRemote(ctx, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	parentCtx, parent := Start(ctx, "parent")
+	_, child := Start(parentCtx, "child")
+	child.SetAttr("step", "start")
+	child.SetError(errors.New("failed"))
+	child.End()
+	parent.End()
+	a.True(strings.HasPrefix(Traceparent(parentCtx), "00-4bf92f3577b34da6a3ce929d0e0e4736-"))
+	Stop(ctx)
+
+	buf, err := ioutil.ReadFile(file)
+	a.NoError(err)
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	if !a.Len(lines, 2) {
+		return
+	}
+	var spans [2]SpanData
+	for i := range lines {
+		a.NoError(json.Unmarshal([]byte(lines[i]), &spans[i]))
+	}
+	a.Equal("child", spans[0].Name)
+	a.Equal("parent", spans[1].Name)
+	a.Equal("4bf92f3577b34da6a3ce929d0e0e4736", spans[0].TraceID)
+	a.Equal("4bf92f3577b34da6a3ce929d0e0e4736", spans[1].TraceID)
+	a.Equal("00f067aa0ba902b7", spans[1].ParentID)
+	a.Equal(spans[1].SpanID, spans[0].ParentID)
+	a.Equal("start", spans[0].Attributes["step"])
+	a.Equal("failed", spans[0].Error)
+	a.False(spans[0].End.Before(spans[0].Start))
+}
+
+func TestTrace_OTLP(t *testing.T) {
+	l := log.Output(ioutil.Discard)
+	ctx := l.WithContext(context.Background())
+	a := assert.New(t)
+	var (
+		requests []otlpRequest
+		headers  []string
+		mu       sync.Mutex
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		headers = append(headers, r.Header.Get("X-Api-Key"))
+		mu.Unlock()
+	}))
+	defer collector.Close()
+	Init(ctx, Config{
+		Exporter:    "otlp",
+		Endpoint:    collector.URL + "/v1/traces",
+		Headers:     map[string]string{"X-Api-Key": "secret"},
+		ServiceName: "test",
+		BatchSize:   2,
+	})
+	defer Stop(ctx)
+
+	rootCtx, root := Start(ctx, "root")
+	for i := 0; i < 2; i++ {
+		_, span := Start(rootCtx, "child")
+		span.SetError(errors.New("failed"))
+		span.End()
+	}
+	root.End()
+	Stop(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !a.Len(requests, 2) {
+		return
+	}
+	a.Equal([]string{"secret", "secret"}, headers)
+	rs := requests[0].ResourceSpans[0]
+	a.Equal("service.name", rs.Resource.Attributes[0].Key)
+	a.Equal("test", rs.Resource.Attributes[0].Value.StringValue)
+	spans := append(rs.ScopeSpans[0].Spans, requests[1].ResourceSpans[0].ScopeSpans[0].Spans...)
+	if !a.Len(spans, 3) {
+		return
+	}
+	a.Equal("root", spans[2].Name)
+	a.Empty(spans[2].ParentSpanID)
+	a.Nil(spans[2].Status)
+	for _, span := range spans[:2] {
+		a.Equal(spans[2].TraceID, span.TraceID)
+		a.Equal(spans[2].SpanID, span.ParentSpanID)
+		a.Equal(otlpStatusError, span.Status.Code)
+		a.NotEmpty(span.StartTimeUnixNano)
+	}
+}
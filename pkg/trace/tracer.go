@@ -0,0 +1,186 @@
+package trace
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	//defaultServiceName default name of the service reported with the spans
+	defaultServiceName = "manopus"
+	//defaultBatchSize default maximum number of spans exported at once
+	defaultBatchSize = 512
+	//defaultInterval default interval in seconds between exports of the spans
+	defaultInterval = 5
+	//maxQueueBatches number of batches queued before new spans are dropped
+	maxQueueBatches = 8
+)
+
+// SpanData describes finished span
+type SpanData struct {
+	TraceID    string            `json:"trace_id"`
+	SpanID     string            `json:"span_id"`
+	ParentID   string            `json:"parent_id,omitempty"`
+	Name       string            `json:"name"`
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// tracer queues finished spans and exports them in batches
+type tracer struct {
+	ctx      context.Context
+	config   Config
+	exporter exporter
+	queue    []SpanData
+	dropped  int
+	flush    chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	//exportMu serializes exports so spans are exported in order
+	exportMu sync.Mutex
+	sync.Mutex
+}
+
+// registry holds the active tracer
+type registry struct {
+	tracer *tracer
+	config Config
+	sync.RWMutex
+}
+
+var global registry
+
+// Init configures tracing. It can be called again to apply the new configuration.
+func Init(ctx context.Context, config Config) {
+	l := logger(ctx).With().Str("trace_exporter", config.Exporter).Logger()
+	global.Lock()
+	defer global.Unlock()
+	if reflect.DeepEqual(global.config, config) {
+		return
+	}
+	if global.tracer != nil {
+		global.tracer.close()
+		global.tracer = nil
+	}
+	global.config = config
+	if config.Exporter == "" {
+		return
+	}
+	e, err := newExporter(config)
+	if err != nil {
+		l.Error().Err(err).Msg("Cannot configure exporter, tracing is disabled")
+		global.config = Config{}
+		return
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = defaultServiceName
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	t := &tracer{
+		ctx:      ctx,
+		config:   config,
+		exporter: e,
+		flush:    make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+	global.tracer = t
+	l.Info().Msg("Tracing event processing")
+}
+
+// Stop exports the queued spans and disables tracing
+func Stop(ctx context.Context) {
+	global.Lock()
+	defer global.Unlock()
+	if global.tracer != nil {
+		global.tracer.close()
+		global.tracer = nil
+	}
+	global.config = Config{}
+}
+
+// active returns the active tracer or nil if tracing is disabled
+func (g *registry) active() *tracer {
+	g.RLock()
+	defer g.RUnlock()
+	return g.tracer
+}
+
+// enqueue queues finished span for export
+func (t *tracer) enqueue(data SpanData) {
+	t.Lock()
+	defer t.Unlock()
+	if len(t.queue) >= t.config.BatchSize*maxQueueBatches {
+		t.dropped++
+		return
+	}
+	t.queue = append(t.queue, data)
+	if len(t.queue) >= t.config.BatchSize {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run exports spans periodically or when the batch is full
+func (t *tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(time.Duration(t.config.Interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			t.export()
+			return
+		case <-ticker.C:
+			t.export()
+		case <-t.flush:
+			t.export()
+		}
+	}
+}
+
+// export exports all queued spans in batches
+func (t *tracer) export() {
+	l := logger(t.ctx)
+	t.exportMu.Lock()
+	defer t.exportMu.Unlock()
+	t.Lock()
+	queue, dropped := t.queue, t.dropped
+	t.queue, t.dropped = nil, 0
+	t.Unlock()
+	if dropped > 0 {
+		l.Warn().Int("trace_dropped_spans", dropped).Msg("Export queue is full, spans have been dropped")
+	}
+	for len(queue) > 0 {
+		n := t.config.BatchSize
+		if n > len(queue) {
+			n = len(queue)
+		}
+		if err := t.exporter.export(t.ctx, t.config.ServiceName, queue[:n]); err != nil {
+			l.Error().Err(err).Int("trace_spans", n).Msg("Error on exporting spans")
+		}
+		queue = queue[n:]
+	}
+}
+
+// close stops the export loop, exports the queued spans and closes the exporter
+func (t *tracer) close() {
+	l := logger(t.ctx)
+	close(t.stop)
+	<-t.done
+	if err := t.exporter.close(); err != nil {
+		l.Error().Err(err).Msg("Error on closing exporter")
+	}
+}